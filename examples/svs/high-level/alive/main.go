@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -48,9 +49,16 @@ func main() {
 		FormalEncoding: false,
 	}
 	sync := svs.NewHealthSync(app, config, svs.GetDefaultConstants())
-	sync.Listen()
-	sync.Activate(true)
-	defer sync.Shutdown()
+	ctx := context.Background()
+	if err = sync.Listen(ctx); err != nil {
+		logger.Errorf("Unable to listen: %+v", err)
+		return
+	}
+	if err = sync.Activate(ctx, true); err != nil {
+		logger.Errorf("Unable to activate: %+v", err)
+		return
+	}
+	defer sync.Shutdown(ctx)
 
 	sigChannel := make(chan os.Signal, 1)
 	signal.Notify(sigChannel, os.Interrupt, syscall.SIGTERM)
@@ -60,11 +68,14 @@ loopCount:
 	for {
 		select {
 		case change := <-recv:
-			if change.OldStatus == svs.Unseen {
+			switch {
+			case change.OldStatus == svs.Unseen:
 				fmt.Printf("%s is heard.\n", change.Node)
-			} else if change.OldStatus == svs.Expired {
+			case change.NewStatus == svs.Left:
+				fmt.Printf("%s left.\n", change.Node)
+			case change.NewStatus == svs.Renewed:
 				fmt.Printf("%s renewed.\n", change.Node)
-			} else {
+			default:
 				fmt.Printf("%s expired.\n", change.Node)
 			}
 		case <-sigChannel:
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -49,18 +50,33 @@ func main() {
 	callback := func(source enc.Name, seqno uint64, data ndn.Data) {
 		inputMutex.Lock()
 		fmt.Print("\n\033[1F\033[K")
-		if data != nil {
-			fmt.Println(source.String() + ": " + string(data.Content().Join()))
-		} else {
-			fmt.Println("Unfetchable")
-		}
+		fmt.Println(source.String() + ": " + string(data.Content().Join()))
 		fmt.Print(input)
 		inputMutex.Unlock()
 	}
-	sync := svs.NewSharedSync(app, svs.GetBasicSharedConfig(sourceName, syncPrefix, callback), svs.GetDefaultConstants())
-	sync.Listen()
-	sync.Activate(true)
-	defer sync.Shutdown()
+	config := svs.GetBasicSharedConfig(sourceName, syncPrefix, callback)
+	config.ErrorCallback = func(source enc.Name, seqno uint64, err svs.FetchError) {
+		inputMutex.Lock()
+		fmt.Print("\n\033[1F\033[K")
+		fmt.Printf("Unfetchable %s:%d (%v)\n", source, seqno, err)
+		fmt.Print(input)
+		inputMutex.Unlock()
+	}
+	sync, err := svs.NewSharedSync(app, config, svs.GetDefaultConstants())
+	if err != nil {
+		logger.Errorf("Unable to create sync: %+v", err)
+		return
+	}
+	ctx := context.Background()
+	if err = sync.Listen(ctx); err != nil {
+		logger.Errorf("Unable to listen: %+v", err)
+		return
+	}
+	if err = sync.Activate(ctx, true); err != nil {
+		logger.Errorf("Unable to activate: %+v", err)
+		return
+	}
+	defer sync.Shutdown(ctx)
 	fmt.Println("Entered the chatroom " + syncPrefix.String() + " as " + sourceName.String() + ".")
 
 	if err := kyb.Open(); err != nil {
@@ -83,8 +99,11 @@ InputLoop:
 		case kyb.KeyEnter:
 			fmt.Print("\n\033[1F\033[K")
 			if strings.TrimSpace(input) != "" {
-				sync.PublishData([]byte(input))
-				fmt.Println(sourceName.String() + ": " + input)
+				if _, err := sync.PublishData([]byte(input)); err != nil {
+					fmt.Printf("Unable to publish: %v\n", err)
+				} else {
+					fmt.Println(sourceName.String() + ": " + input)
+				}
 			}
 			input = ""
 		case kyb.KeyBackspace:
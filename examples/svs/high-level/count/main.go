@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -46,18 +47,29 @@ func main() {
 	syncPrefix, _ := enc.NameFromStr("/svs")
 	sourceName, _ := enc.NameFromStr(*source)
 	callback := func(source enc.Name, seqno uint64, data ndn.Data) {
-		if data != nil {
-			fmt.Println(source.String() + ": " + string(data.Content().Join()))
-		} else {
-			fmt.Println("Unfetchable")
-		}
+		fmt.Println(source.String() + ": " + string(data.Content().Join()))
+	}
+	config := svs.GetBasicNativeConfig(sourceName, syncPrefix, callback)
+	config.ErrorCallback = func(source enc.Name, seqno uint64, err svs.FetchError) {
+		fmt.Printf("Unfetchable %s:%d (%v)\n", source, seqno, err)
+	}
+	sync, err := svs.NewNativeSync(app, config, svs.GetDefaultConstants())
+	if err != nil {
+		logger.Errorf("Unable to create sync: %+v", err)
+		return
 	}
-	sync := svs.NewNativeSync(app, svs.GetBasicNativeConfig(sourceName, syncPrefix, callback), svs.GetDefaultConstants())
 
 	fmt.Println("Activating ...")
-	sync.Listen()
-	sync.Activate(true)
-	defer sync.Shutdown()
+	ctx := context.Background()
+	if err = sync.Listen(ctx); err != nil {
+		logger.Errorf("Unable to listen: %+v", err)
+		return
+	}
+	if err = sync.Activate(ctx, true); err != nil {
+		logger.Errorf("Unable to activate: %+v", err)
+		return
+	}
+	defer sync.Shutdown(ctx)
 	fmt.Printf("Activated.\n\n")
 
 	num := 1
@@ -70,8 +82,11 @@ loopCount:
 	for {
 		select {
 		case <-clock.C:
-			sync.PublishData([]byte(strconv.Itoa(num)))
-			fmt.Println("Published: " + strconv.Itoa(num))
+			if _, err := sync.PublishData([]byte(strconv.Itoa(num))); err != nil {
+				fmt.Printf("Unable to publish: %v\n", err)
+			} else {
+				fmt.Println("Published: " + strconv.Itoa(num))
+			}
 			clock.Reset(time.Duration(*interval) * time.Millisecond)
 			num++
 		case <-sigChannel:
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -45,11 +46,11 @@ func main() {
 
 	dataCall := func(source enc.Name, seqno uint64, data ndn.Data) {
 		fmt.Print("\n\033[1F\033[K")
-		if data != nil {
-			fmt.Println(source.String() + ": " + string(data.Content().Join()))
-		} else {
-			fmt.Println("Unfetchable")
-		}
+		fmt.Println(source.String() + ": " + string(data.Content().Join()))
+	}
+	errorCall := func(source enc.Name, seqno uint64, err svs.FetchError) {
+		fmt.Print("\n\033[1F\033[K")
+		fmt.Printf("Unfetchable %s:%d (%v)\n", source, seqno, err)
 	}
 	syncPrefix, _ := enc.NameFromStr("/svs")
 	nid, _ := enc.NameFromStr(*source)
@@ -59,16 +60,28 @@ func main() {
 		NamingScheme:         svs.SourceOrientedNaming,
 		StoragePath:          "./" + *source + "_bolt.db",
 		DataCallback:         dataCall,
+		ErrorCallback:        errorCall,
 		HandlingOption:       svs.NoHandling,
 		FormalEncoding:       false,
 		EfficientSuppression: true,
 	}
-	sync := svs.NewNativeSync(app, config, svs.GetDefaultConstants())
+	sync, err := svs.NewNativeSync(app, config, svs.GetDefaultConstants())
+	if err != nil {
+		logger.Errorf("Unable to create sync: %+v", err)
+		return
+	}
 
 	fmt.Println("Activating ...")
-	sync.Listen()
-	sync.Activate(true)
-	defer sync.Shutdown()
+	ctx := context.Background()
+	if err = sync.Listen(ctx); err != nil {
+		logger.Errorf("Unable to listen: %+v", err)
+		return
+	}
+	if err = sync.Activate(ctx, true); err != nil {
+		logger.Errorf("Unable to activate: %+v", err)
+		return
+	}
+	defer sync.Shutdown(ctx)
 	fmt.Printf("Activated.\n\n")
 
 	num := 1
@@ -82,8 +95,11 @@ loopCount:
 	for {
 		select {
 		case <-send.C:
-			sync.PublishData([]byte(strconv.Itoa(num)))
-			fmt.Println("Published: " + strconv.Itoa(num))
+			if _, err := sync.PublishData([]byte(strconv.Itoa(num))); err != nil {
+				fmt.Printf("Unable to publish: %v\n", err)
+			} else {
+				fmt.Println("Published: " + strconv.Itoa(num))
+			}
 			send.Reset(time.Duration(*interval) * time.Millisecond)
 			num++
 		case missing := <-recv:
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -53,9 +54,16 @@ func main() {
 		FormalEncoding: false,
 	}
 	core := svs.NewCore(app, config, svs.GetDefaultConstants())
-	core.Listen()
-	core.Activate(true)
-	defer core.Shutdown()
+	ctx := context.Background()
+	if err = core.Listen(ctx); err != nil {
+		logger.Errorf("Unable to listen: %+v", err)
+		return
+	}
+	if err = core.Activate(ctx, true); err != nil {
+		logger.Errorf("Unable to activate: %+v", err)
+		return
+	}
+	defer core.Shutdown(ctx)
 	fmt.Printf("Activated.\n\n")
 
 	sigChannel := make(chan os.Signal, 1)
@@ -6,6 +6,7 @@ require (
 	github.com/apex/log v1.9.0
 	github.com/eiannone/keyboard v0.0.0-20220611211555-0d226195f203
 	github.com/stretchr/testify v1.8.4
+	github.com/syndtr/goleveldb v1.0.0
 	github.com/zjkmxy/go-ndn v0.0.6
 	go.etcd.io/bbolt v1.3.9
 )
@@ -13,6 +14,7 @@ require (
 require (
 	github.com/cespare/xxhash v1.1.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db // indirect
 	github.com/gorilla/websocket v1.5.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
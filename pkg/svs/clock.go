@@ -0,0 +1,170 @@
+package svs
+
+import (
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time and randomness for Schedulers, Trackers, Cores, and HealthSync.
+type Clock interface {
+	Now() time.Time
+	NewTimer(time.Duration) Timer
+	Rand(time.Duration) time.Duration // uniform in [0, n)
+}
+
+// Timer behaves as a time.Timer of its Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(time.Duration) bool
+}
+
+type systemClock struct{}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func NewSystemClock() Clock {
+	return systemClock{}
+}
+
+func (systemClock) Now() time.Time                     { return time.Now() }
+func (systemClock) NewTimer(d time.Duration) Timer     { return systemTimer{time.NewTimer(d)} }
+func (systemClock) Rand(n time.Duration) time.Duration { return rand.N(n) }
+
+func (t systemTimer) C() <-chan time.Time        { return t.t.C }
+func (t systemTimer) Stop() bool                 { return t.t.Stop() }
+func (t systemTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+// VirtualClock only moves through Advance, firing due timers in order. With the same seed,
+// the same calls to Rand return the same values.
+type VirtualClock struct {
+	mtx    sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	rng    *rand.Rand
+	timers []*virtualTimer
+}
+
+type virtualTimer struct {
+	clock *VirtualClock
+	c     chan time.Time
+	at    time.Time
+}
+
+func NewVirtualClock(start time.Time, seed uint64) *VirtualClock {
+	c := &VirtualClock{
+		now: start,
+		rng: rand.New(rand.NewPCG(seed, seed)),
+	}
+	c.cond = sync.NewCond(&c.mtx)
+	return c
+}
+
+func (c *VirtualClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+func (c *VirtualClock) NewTimer(d time.Duration) Timer {
+	t := &virtualTimer{clock: c, c: make(chan time.Time, 1)}
+	c.mtx.Lock()
+	c.arm(t, d)
+	c.mtx.Unlock()
+	return t
+}
+
+func (c *VirtualClock) Rand(n time.Duration) time.Duration {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return time.Duration(c.rng.Int64N(int64(n)))
+}
+
+// Moves the clock forward by d. A timer due on the way fires with the clock set to its deadline.
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	end := c.now.Add(d)
+	for len(c.timers) > 0 && !c.timers[0].at.After(end) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.at
+		select {
+		case t.c <- t.at:
+		default:
+		}
+	}
+	c.now = end
+	c.cond.Broadcast()
+}
+
+// Returns how long until the next timer fires, false if none is armed.
+func (c *VirtualClock) Next() (time.Duration, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if len(c.timers) == 0 {
+		return 0, false
+	}
+	return c.timers[0].at.Sub(c.now), true
+}
+
+// Waits until at least n timers are armed, e.g. until routines woken by Advance rearm theirs.
+func (c *VirtualClock) BlockUntil(n int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// Must be called with the lock held. A timer that is already due fires right away.
+func (c *VirtualClock) arm(t *virtualTimer, d time.Duration) {
+	t.at = c.now.Add(d)
+	if d <= 0 {
+		select {
+		case t.c <- t.at:
+		default:
+		}
+		return
+	}
+	i := sort.Search(len(c.timers), func(i int) bool { return c.timers[i].at.After(t.at) })
+	c.timers = append(c.timers, nil)
+	copy(c.timers[i+1:], c.timers[i:])
+	c.timers[i] = t
+	c.cond.Broadcast()
+}
+
+// Must be called with the lock held.
+func (c *VirtualClock) disarm(t *virtualTimer) bool {
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *virtualTimer) C() <-chan time.Time { return t.c }
+
+func (t *virtualTimer) Stop() bool {
+	t.clock.mtx.Lock()
+	defer t.clock.mtx.Unlock()
+	return t.clock.disarm(t)
+}
+
+func (t *virtualTimer) Reset(d time.Duration) bool {
+	t.clock.mtx.Lock()
+	defer t.clock.mtx.Unlock()
+	active := t.clock.disarm(t)
+	t.clock.arm(t, d)
+	return active
+}
+
+func boundedRand(clock Clock, min, max time.Duration) time.Duration {
+	return min + clock.Rand(max-min+1)
+}
@@ -1,13 +1,30 @@
 package svs
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
 	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
 )
 
 const (
-	TypeVector     enc.TLNum = 0xc9
-	TypeEntry      enc.TLNum = 0xca
-	TypeEntrySeqno enc.TLNum = 0xcc
+	TypeVector           enc.TLNum = 0xc9
+	TypeEntry            enc.TLNum = 0xca
+	TypeEntrySeqno       enc.TLNum = 0xcc
+	TypeCompressedVector enc.TLNum = 0xcd
+	TypeMappingEntry     enc.TLNum = 0xce
+)
+
+var (
+	ErrAlreadyListening = errors.New("svs: already listening")
+	ErrAlreadyActive    = errors.New("svs: already active")
+	ErrMissingCallback  = errors.New("svs: missing DataCallback")
+	ErrForeignDataset   = errors.New("svs: dataset is not under the source")
+	ErrTooManySegments  = errors.New("svs: publication exceeds MaxSegments")
 )
 
 type NamingScheme int
@@ -26,12 +43,20 @@ const (
 	EqualTrafficHandling  HandlingOption = 2
 )
 
+type OverflowPolicy int
+
+const (
+	DropOldest OverflowPolicy = 0
+	Coalesce   OverflowPolicy = 1
+)
+
 type Status int
 
 const (
 	Unseen  Status = 0
 	Expired Status = 1
 	Renewed Status = 2
+	Left    Status = 3
 )
 
 type SyncUpdate []MissingData
@@ -53,3 +78,111 @@ type StatusChange struct {
 	OldStatus Status
 	NewStatus Status
 }
+
+type FetchError struct {
+	Result     ndn.InterestResult
+	NackReason uint64
+	Retries    uint
+}
+
+func (e FetchError) Error() string {
+	switch e.Result {
+	case ndn.InterestResultNack:
+		return fmt.Sprintf("nacked with reason %d after %d retries", e.NackReason, e.Retries)
+	case ndn.InterestResultTimeout:
+		return fmt.Sprintf("timed out after %d retries", e.Retries)
+	case ndn.InterestCancelled:
+		return fmt.Sprintf("cancelled after %d retries", e.Retries)
+	default:
+		return fmt.Sprintf("failed with result %d after %d retries", e.Result, e.Retries)
+	}
+}
+
+// Route commands of an engine sign with a shared signer that is not safe for concurrent use,
+// and registrations left to finish in the background may overlap with later ones.
+var routeMtx sync.Mutex
+
+func routeCommand(command func(enc.Name) error, prefix enc.Name) error {
+	routeMtx.Lock()
+	defer routeMtx.Unlock()
+	return command(prefix)
+}
+
+// Registers the route unless ctx is done first. A registration that still succeeds after ctx is
+// done is withdrawn, so a Listen that failed leaves no route behind.
+func registerRoute(ctx context.Context, app *eng.Engine, prefix enc.Name) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- routeCommand(app.RegisterRoute, prefix) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		go func() {
+			if <-done == nil {
+				routeCommand(app.UnregisterRoute, prefix)
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
+// Withdraws the route and waits for it unless ctx is done first. The withdrawal is sent even
+// when ctx is already done, so that cleaning up late still leaves no route behind.
+func unregisterRoute(ctx context.Context, app *eng.Engine, prefix enc.Name) error {
+	done := make(chan error, 1)
+	go func() { done <- routeCommand(app.UnregisterRoute, prefix) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// The engine runs Express callbacks while holding its PIT lock, so a callback that may
+// express another Interest has to run on its own routine.
+func detached(callback ndn.ExpressCallbackFunc) ndn.ExpressCallbackFunc {
+	return func(result ndn.InterestResult, data ndn.Data, rawData, sigCovered enc.Wire, nackReason uint64) {
+		go callback(result, data, rawData, sigCovered, nackReason)
+	}
+}
+
+// callQueue runs the callbacks of a Sync one at a time and in order. A call made while another
+// runs, including from within it, is queued and run by the routine already running calls.
+type callQueue struct {
+	mtx     sync.Mutex
+	calls   []func()
+	running bool
+}
+
+func (q *callQueue) run(call func()) {
+	q.mtx.Lock()
+	q.calls = append(q.calls, call)
+	if q.running {
+		q.mtx.Unlock()
+		return
+	}
+	q.running = true
+	for len(q.calls) > 0 {
+		call = q.calls[0]
+		q.calls[0] = nil
+		q.calls = q.calls[1:]
+		q.mtx.Unlock()
+		call()
+		q.mtx.Lock()
+	}
+	q.running = false
+	q.mtx.Unlock()
+}
+
+func waitContext(ctx context.Context, ch <-chan struct{}) error {
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
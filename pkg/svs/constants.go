@@ -11,21 +11,34 @@ type Constants struct {
 	SuppressionInterval            time.Duration
 	SyncIntervalJitter             float64 // percentage variance 0.00<=x<=1.00
 	SuppressionIntervalJitter      float64 // percentage variance 0.00<=x<=1.00
+	ShakingThreshold               uint    // inconsistent vectors within ShakingWindow that start shaking, 0 = never
+	ShakingWindow                  time.Duration
+	ShakingInterval                time.Duration // sync interval while shaking
+	ShakingIntervalJitter          float64       // percentage variance 0.00<=x<=1.00
+	ShakingRounds                  uint          // Sync Interests in a row without inconsistency that end shaking
 	DataInterestLifeTime           time.Duration
 	DataInterestRetries            uint // 0 = no retry
 	DataPacketFreshness            time.Duration
+	MaxSegmentSize                 int  // content bytes per Data packet, larger publications are segmented
+	MaxSegments                    uint // segments of a publication, larger ones are neither published nor fetched
 	SyncInterestLifeTime           time.Duration
+	PartialVectorRecent            uint // most recently updated entries in a partial vector
+	PartialVectorRotation          uint // older entries in a partial vector, rotating between Interests
 	DataComponent                  enc.Component
 	SyncComponent                  enc.Component
+	MappingComponent               enc.Component
+	LeaveComponent                 enc.Component // names the leave dataset of a HealthSync node
+	StatusComponent                enc.Component
+	MappingBatchSize               uint  // seqnos per mapping Interest
 	MaxConcurrentDataInterests     int32 // 0 = inf
 	InitialFetchQueueSize          uint  // only helps to mitigate allocation resizing
-	InitialMissingChannelSize      uint  // only helps to mitigate allocation resizing
-	InitialStatusChangeChannelSize uint  // only helps to mitigate allocation resizing
+	InitialStatusChangeChannelSize uint  // bounds the default Tracker subscription
 	HeartbeatsToRenew              uint
 	HeartbeatsToExpire             uint
 	TrackRate                      time.Duration
 	HeartbeatRate                  time.Duration
-	MonitorInterval                time.Duration
+	StatusPullRate                 time.Duration // 0 = only pulled through NeedStatus
+	Clock                          Clock         // a VirtualClock drives the timing of tests
 }
 
 func GetDefaultConstants() *Constants {
@@ -34,10 +47,19 @@ func GetDefaultConstants() *Constants {
 		SuppressionInterval:       200 * time.Millisecond,
 		SyncIntervalJitter:        0.10,
 		SuppressionIntervalJitter: 0.50,
+		ShakingThreshold:          0,
+		ShakingWindow:             1000 * time.Millisecond,
+		ShakingInterval:           500 * time.Millisecond,
+		ShakingIntervalJitter:     0.20,
+		ShakingRounds:             3,
 		DataInterestLifeTime:      2000 * time.Millisecond,
 		DataInterestRetries:       2,
 		DataPacketFreshness:       5000 * time.Millisecond,
+		MaxSegmentSize:            8000,
+		MaxSegments:               1024,
 		SyncInterestLifeTime:      1000 * time.Millisecond,
+		PartialVectorRecent:       16,
+		PartialVectorRotation:     8,
 		DataComponent: enc.Component{
 			Typ: enc.TypeGenericNameComponent,
 			Val: []byte{100, 97, 116, 97},
@@ -46,14 +68,23 @@ func GetDefaultConstants() *Constants {
 			Typ: enc.TypeGenericNameComponent,
 			Val: []byte{115, 121, 110, 99},
 		},
+		MappingComponent: enc.Component{
+			Typ: enc.TypeGenericNameComponent,
+			Val: []byte{109, 97, 112, 112, 105, 110, 103},
+		},
+		LeaveComponent: enc.Component{
+			Typ: enc.TypeKeywordNameComponent,
+			Val: []byte{108, 101, 97, 118, 101},
+		},
+		MappingBatchSize:               50,
 		MaxConcurrentDataInterests:     10,
 		InitialFetchQueueSize:          50,
-		InitialMissingChannelSize:      5,
 		InitialStatusChangeChannelSize: 5,
 		HeartbeatsToRenew:              3,
 		HeartbeatsToExpire:             3,
 		TrackRate:                      50000 * time.Millisecond,
 		HeartbeatRate:                  45000 * time.Millisecond,
-		MonitorInterval:                10 * time.Millisecond,
+		StatusPullRate:                 0,
+		Clock:                          NewSystemClock(),
 	}
 }
@@ -1,32 +1,53 @@
 package svs
 
 import (
+	"context"
+	"slices"
+	"sync"
 	"time"
 
 	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
 	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
 	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	sec "github.com/zjkmxy/go-ndn/pkg/security"
 )
 
 type Core interface {
-	Listen()
-	Activate(bool)
-	Shutdown()
+	Listen(context.Context) error
+	Activate(context.Context, bool) error
+	Shutdown(context.Context) error
 	Update(enc.Name, uint64)
 	StateVector() *StateVector
 	FeedInterest(ndn.Interest, enc.Wire, enc.Wire, ndn.ReplyFunc, time.Time)
-	Subscribe() chan SyncUpdate
+	Subscribe() <-chan SyncUpdate
+	Unsubscribe(<-chan SyncUpdate)
 }
 
 type OneStateCoreConfig struct {
-	SyncPrefix     enc.Name
-	FormalEncoding bool
+	SyncPrefix         enc.Name
+	FormalEncoding     bool
+	CompressedEncoding bool       // overrides FormalEncoding when sending
+	PartialVector      bool       // every member of the group must agree
+	Signer             ndn.Signer // nil = sha256 digest
+	Validator          Validator  // nil = sha256 digest
+	InitialState       *StateVector
+	SelfDatasets       []enc.Name // datasets the node updated within InitialState
+	Metrics            Metrics    // nil = none
+	EventSink          EventSink  // nil = none
 }
 
 type TwoStateCoreConfig struct {
 	SyncPrefix           enc.Name
 	FormalEncoding       bool
+	CompressedEncoding   bool // overrides FormalEncoding when sending
 	EfficientSuppression bool
+	PartialVector        bool       // every member of the group must agree
+	Signer               ndn.Signer // nil = sha256 digest
+	Validator            Validator  // nil = sha256 digest
+	InitialState         *StateVector
+	SelfDatasets         []enc.Name // datasets the node updated within InitialState
+	Metrics              Metrics    // nil = none
+	EventSink            EventSink  // nil = none
 }
 
 func NewCore(app *eng.Engine, config interface{}, constants *Constants) Core {
@@ -39,3 +60,86 @@ func NewCore(app *eng.Engine, config interface{}, constants *Constants) Core {
 		return newNullCore()
 	}
 }
+
+func coreSecurity(signer ndn.Signer, validator Validator) (ndn.Signer, Validator) {
+	if signer == nil {
+		signer = sec.NewSha256IntSigner(eng.NewTimer())
+	}
+	if validator == nil {
+		validator = NewSha256Validator()
+	}
+	return signer, validator
+}
+
+func restoreState(local *StateVector, initial *StateVector, datasets []enc.Name) []string {
+	selfsets := make([]string, 0, len(datasets))
+	if initial != nil {
+		for p := initial.Entries().Front(); p != nil; p = p.Next() {
+			local.Set(p.Kstr, p.Kname, p.Val, true)
+		}
+	}
+	for _, ds := range datasets {
+		selfsets = append(selfsets, ds.String())
+	}
+	return selfsets
+}
+
+// fanout hands SyncUpdates to every subscriber without blocking. While a subscriber
+// is busy, its pending updates are merged into one with a single range per dataset.
+type fanout struct {
+	mtx  sync.Mutex
+	subs []*mailbox[SyncUpdate]
+}
+
+func (f *fanout) subscribe() <-chan SyncUpdate {
+	mb := newMailbox(1, mergeUpdates)
+	f.mtx.Lock()
+	f.subs = append(f.subs, mb)
+	f.mtx.Unlock()
+	return mb.out
+}
+
+func (f *fanout) unsubscribe(ch <-chan SyncUpdate) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.subs = slices.DeleteFunc(f.subs, func(mb *mailbox[SyncUpdate]) bool {
+		if (<-chan SyncUpdate)(mb.out) != ch {
+			return false
+		}
+		mb.close()
+		return true
+	})
+}
+
+func (f *fanout) publish(update SyncUpdate) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	for _, mb := range f.subs {
+		mb.push(update)
+	}
+}
+
+func (f *fanout) close() {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	for _, mb := range f.subs {
+		mb.close()
+	}
+	f.subs = nil
+}
+
+// Updates are shared between subscribers, so the pending one is copied before merging.
+func mergeUpdates(queue []SyncUpdate, update SyncUpdate) ([]SyncUpdate, bool) {
+	merged := slices.Clone(queue[0])
+	for _, m := range update {
+		i := slices.IndexFunc(merged, func(p MissingData) bool { return p.Dataset.Equal(m.Dataset) })
+		if i < 0 {
+			merged = append(merged, m)
+			continue
+		}
+		merged[i].StartSeq = min(merged[i].StartSeq, m.StartSeq)
+		merged[i].EndSeq = max(merged[i].EndSeq, m.EndSeq)
+	}
+	queue[0] = merged
+	return queue, false
+}
@@ -1,18 +1,28 @@
 package svs
 
 import (
+	"encoding/binary"
 	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
 
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
 	bolt "go.etcd.io/bbolt"
 )
 
+// Packets are keyed by their encoded name which always starts with the Name type,
+// so these keys never collide with them.
+var (
+	stateVectorKey = []byte("svs-state-vector")
+	sequenceKey    = []byte("svs-sequence")
+)
+
 type Database interface {
 	Get([]byte) []byte
 	Set([]byte, []byte) error
 	Remove([]byte) error
+	ForEach(func(key []byte, val []byte) bool) // stops once false is returned
 	Close()
 }
 
@@ -68,10 +78,52 @@ func (fs BoltDB) Remove(key []byte) error {
 	})
 }
 
+func (fs BoltDB) ForEach(f func(key []byte, val []byte) bool) {
+	fs.handle.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(fs.bucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if !f(k, v) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
 func (fs BoltDB) Close() {
 	fs.handle.Close()
 }
 
+func storeSequence(db Database, seqno uint64) error {
+	return db.Set(sequenceKey, binary.BigEndian.AppendUint64(nil, seqno))
+}
+
+func storeStateVector(db Database, sv *StateVector) error {
+	sv.RLock()
+	wire := sv.Encode(true)
+	sv.RUnlock()
+	return db.Set(stateVectorKey, wire.Join())
+}
+
+// Returns a nil StateVector when nothing was previously stored.
+func loadState(db Database) (*StateVector, uint64, error) {
+	var (
+		sv    *StateVector
+		seqno uint64
+		err   error
+	)
+	if b := db.Get(sequenceKey); len(b) == 8 {
+		seqno = binary.BigEndian.Uint64(b)
+	}
+	if b := db.Get(stateVectorKey); b != nil {
+		sv, err = ParseStateVector(enc.NewBufferReader(b), true)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return sv, seqno, nil
+}
+
 func ensureDirectory(path string) error {
 	dir := filepath.Dir(path)
 	return os.MkdirAll(dir, os.ModePerm)
@@ -0,0 +1,168 @@
+package svs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type EventType int
+
+const (
+	InterestReceivedEvent   EventType = 0
+	InterestRejectedEvent   EventType = 1
+	VectorMergedEvent       EventType = 2
+	SuppressionEnteredEvent EventType = 3
+	TimerFiredEvent         EventType = 4
+	InterestSentEvent       EventType = 5
+	InterestSuppressedEvent EventType = 6
+	ShakingEnteredEvent     EventType = 7
+	ShakingExitedEvent      EventType = 8
+)
+
+var eventTypeNames = [...]string{
+	"interest_received",
+	"interest_rejected",
+	"vector_merged",
+	"suppression_entered",
+	"timer_fired",
+	"interest_sent",
+	"interest_suppressed",
+	"shaking_entered",
+	"shaking_exited",
+}
+
+func (t EventType) String() string {
+	if t < 0 || int(t) >= len(eventTypeNames) {
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+	return eventTypeNames[t]
+}
+
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *EventType) UnmarshalText(text []byte) error {
+	for i, name := range eventTypeNames {
+		if name == string(text) {
+			*t = EventType(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("svs: unknown event type %q", text)
+}
+
+// Event records a step of a Core, with the vectors it involved. Vectors map datasets to seqnos.
+type Event struct {
+	Type    EventType         `json:"type"`
+	Time    time.Time         `json:"time"`
+	State   string            `json:"state"`             // steady, suppression, or shaking when the step began
+	Remote  map[string]uint64 `json:"remote,omitempty"`  // received vector
+	Local   map[string]uint64 `json:"local,omitempty"`   // local vector after the step, or the vector sent
+	Record  map[string]uint64 `json:"record,omitempty"`  // vectors heard while suppressing
+	Missing []EventRange      `json:"missing,omitempty"` // publications found missing
+	Newer   bool              `json:"newer,omitempty"`   // the local vector is newer than the received one
+	Delay   time.Duration     `json:"delay,omitempty"`   // until the suppression timer fires
+	Reason  string            `json:"reason,omitempty"`
+}
+
+type EventRange struct {
+	Dataset  string `json:"dataset"`
+	StartSeq uint64 `json:"start"`
+	EndSeq   uint64 `json:"end"`
+}
+
+// EventSink receives the Events of a Core. Emit is called on the Core's routines and should return quickly.
+type EventSink interface {
+	Emit(Event)
+}
+
+type jsonEventSink struct {
+	mtx sync.Mutex
+	enc *json.Encoder
+}
+
+// Writes every Event as a line of JSON, which ReadEvents reads back.
+func NewJSONEventSink(w io.Writer) EventSink {
+	return &jsonEventSink{enc: json.NewEncoder(w)}
+}
+
+func (s *jsonEventSink) Emit(e Event) {
+	s.mtx.Lock()
+	s.enc.Encode(e)
+	s.mtx.Unlock()
+}
+
+func ReadEvents(r io.Reader) ([]Event, error) {
+	var (
+		ret     []Event
+		scanner = bufio.NewScanner(r)
+	)
+	scanner.Buffer(nil, 1<<24)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return ret, fmt.Errorf("svs: event on line %d: %w", line, err)
+		}
+		ret = append(ret, e)
+	}
+	return ret, scanner.Err()
+}
+
+type eventEmitter struct {
+	sink  EventSink
+	clock Clock
+}
+
+// Events are only built when a sink is set, as snapshots of vectors are not free.
+func (e eventEmitter) enabled() bool {
+	return e.sink != nil
+}
+
+func (e eventEmitter) emit(ev Event) {
+	if e.sink == nil {
+		return
+	}
+	ev.Time = e.clock.Now()
+	e.sink.Emit(ev)
+}
+
+func stateName(state int32) string {
+	switch state {
+	case steadyState:
+		return "steady"
+	case suppressionState:
+		return "suppression"
+	case shakingState:
+		return "shaking"
+	default:
+		return fmt.Sprintf("state(%d)", state)
+	}
+}
+
+// Must be called with the vector locked.
+func vectorSnapshot(sv *StateVector) map[string]uint64 {
+	ret := make(map[string]uint64, sv.Len())
+	for p := sv.Entries().Front(); p != nil; p = p.Next() {
+		ret[p.Kstr] = p.Val
+	}
+	return ret
+}
+
+func eventRanges(missing SyncUpdate) []EventRange {
+	if len(missing) == 0 {
+		return nil
+	}
+	ret := make([]EventRange, len(missing))
+	for i, m := range missing {
+		ret[i] = EventRange{Dataset: m.Dataset.String(), StartSeq: m.StartSeq, EndSeq: m.EndSeq}
+	}
+	return ret
+}
@@ -0,0 +1,169 @@
+package svs
+
+import (
+	"container/heap"
+
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+)
+
+// FetchItem is a publication waiting to be fetched.
+type FetchItem struct {
+	Source enc.Name
+	Seqno  uint64
+	ref    any
+}
+
+// FetchPolicy orders pending fetches and bounds how many are outstanding.
+// A policy belongs to a single Sync, which calls it under its own lock.
+// Pop must return the FetchItems given to Push, unmodified; any other item is dropped.
+type FetchPolicy interface {
+	Push(FetchItem)
+	Pop() (FetchItem, bool)
+	Window() int // 0 = inf
+	OnData(FetchItem)
+	OnTimeout(FetchItem) // also called on Nacks
+}
+
+type fifoFetchPolicy struct {
+	items  []FetchItem
+	window int
+}
+
+// Fetches in the order publications were found missing.
+func NewFifoFetchPolicy(window int) FetchPolicy {
+	return newFifoFetchPolicy(window, 0)
+}
+
+func newFifoFetchPolicy(window int, capacity uint) *fifoFetchPolicy {
+	return &fifoFetchPolicy{items: make([]FetchItem, 0, capacity), window: window}
+}
+
+func (p *fifoFetchPolicy) Push(item FetchItem) { p.items = append(p.items, item) }
+
+func (p *fifoFetchPolicy) Pop() (FetchItem, bool) {
+	if len(p.items) == 0 {
+		return FetchItem{}, false
+	}
+	item := p.items[0]
+	p.items[0] = FetchItem{}
+	p.items = p.items[1:]
+	return item, true
+}
+
+func (p *fifoFetchPolicy) Window() int         { return p.window }
+func (p *fifoFetchPolicy) OnData(FetchItem)    {}
+func (p *fifoFetchPolicy) OnTimeout(FetchItem) {}
+
+type latestFirstFetchPolicy struct {
+	items  []FetchItem
+	window int
+}
+
+// Fetches the most recently found missing publication first.
+func NewLatestFirstFetchPolicy(window int) FetchPolicy {
+	return &latestFirstFetchPolicy{window: window}
+}
+
+func (p *latestFirstFetchPolicy) Push(item FetchItem) { p.items = append(p.items, item) }
+
+func (p *latestFirstFetchPolicy) Pop() (FetchItem, bool) {
+	if len(p.items) == 0 {
+		return FetchItem{}, false
+	}
+	item := p.items[len(p.items)-1]
+	p.items[len(p.items)-1] = FetchItem{}
+	p.items = p.items[:len(p.items)-1]
+	return item, true
+}
+
+func (p *latestFirstFetchPolicy) Window() int         { return p.window }
+func (p *latestFirstFetchPolicy) OnData(FetchItem)    {}
+func (p *latestFirstFetchPolicy) OnTimeout(FetchItem) {}
+
+type prioritizedItem struct {
+	item  FetchItem
+	prio  int
+	order uint64
+}
+
+type priorityQueue []prioritizedItem
+
+func (q priorityQueue) Len() int { return len(q) }
+func (q priorityQueue) Less(i, j int) bool {
+	if q[i].prio != q[j].prio {
+		return q[i].prio > q[j].prio
+	}
+	return q[i].order < q[j].order
+}
+func (q priorityQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *priorityQueue) Push(x any)   { *q = append(*q, x.(prioritizedItem)) }
+func (q *priorityQueue) Pop() any {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+type priorityFetchPolicy struct {
+	queue    priorityQueue
+	priority func(source enc.Name) int
+	window   int
+	count    uint64
+}
+
+// Fetches sources with a higher priority first, equal priorities in FIFO order.
+func NewPriorityFetchPolicy(window int, priority func(source enc.Name) int) FetchPolicy {
+	return &priorityFetchPolicy{priority: priority, window: window}
+}
+
+func (p *priorityFetchPolicy) Push(item FetchItem) {
+	p.count++
+	heap.Push(&p.queue, prioritizedItem{item: item, prio: p.priority(item.Source), order: p.count})
+}
+
+func (p *priorityFetchPolicy) Pop() (FetchItem, bool) {
+	if p.queue.Len() == 0 {
+		return FetchItem{}, false
+	}
+	return heap.Pop(&p.queue).(prioritizedItem).item, true
+}
+
+func (p *priorityFetchPolicy) Window() int         { return p.window }
+func (p *priorityFetchPolicy) OnData(FetchItem)    {}
+func (p *priorityFetchPolicy) OnTimeout(FetchItem) {}
+
+type aimdFetchPolicy struct {
+	FetchPolicy
+	window float64
+	max    float64
+}
+
+// Grows the window by one per window of Data and halves it on every timeout or Nack.
+// The order is taken from the given policy (nil = FIFO), its window is ignored.
+func NewAimdFetchPolicy(order FetchPolicy, initial int, max int) FetchPolicy {
+	if order == nil {
+		order = NewFifoFetchPolicy(0)
+	}
+	if initial < 1 {
+		initial = 1
+	}
+	return &aimdFetchPolicy{FetchPolicy: order, window: float64(initial), max: float64(max)}
+}
+
+func (p *aimdFetchPolicy) Window() int { return int(p.window) }
+
+func (p *aimdFetchPolicy) OnData(item FetchItem) {
+	p.window += 1 / p.window
+	if p.max > 0 && p.window > p.max {
+		p.window = p.max
+	}
+	p.FetchPolicy.OnData(item)
+}
+
+func (p *aimdFetchPolicy) OnTimeout(item FetchItem) {
+	p.window /= 2
+	if p.window < 1 {
+		p.window = 1
+	}
+	p.FetchPolicy.OnTimeout(item)
+}
@@ -1,15 +1,19 @@
 package svs
 
 import (
+	"context"
+
 	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
 	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
 )
 
 type HealthSync interface {
-	Listen()
-	Activate(bool)
-	Shutdown()
-	// TODO: NeedStatus + PublishStatus (publish/pull at a rate)
+	Listen(context.Context) error
+	Activate(context.Context, bool) error
+	Shutdown(context.Context) error
+	PublishStatus([]byte)
+	NeedStatus(enc.Name)
 	Core() Core
 	Tracker() Tracker
 }
@@ -18,7 +22,16 @@ type HealthConfig struct {
 	Source               enc.Name
 	GroupPrefix          enc.Name
 	FormalEncoding       bool
+	CompressedEncoding   bool // overrides FormalEncoding when sending
 	EfficientSuppression bool
+	PartialVector        bool        // every member of the group must agree
+	SyncSigner           ndn.Signer  // nil = sha256 digest
+	SyncValidator        Validator   // nil = sha256 digest
+	StatusSigner         ndn.Signer  // nil = sha256 digest
+	StatusValidator      Validator   // nil = sha256 digest
+	StatusTrustPolicy    TrustPolicy // nil = any key
+	Metrics              Metrics     // nil = none
+	EventSink            EventSink   // nil = none, receives the events of the Core
 }
 
 func NewHealthSync(app *eng.Engine, config *HealthConfig, constants *Constants) HealthSync {
@@ -1,11 +1,17 @@
 package svs
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	log "github.com/apex/log"
 	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
 	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	sec "github.com/zjkmxy/go-ndn/pkg/security"
+	utl "github.com/zjkmxy/go-ndn/pkg/utils"
 )
 
 type healthHandlerData struct {
@@ -15,26 +21,42 @@ type healthHandlerData struct {
 type healthSync struct {
 	app         *eng.Engine
 	core        Core
-	missChan    chan SyncUpdate
+	missChan    <-chan SyncUpdate
 	tracker     Tracker
 	constants   *Constants
 	groupPrefix enc.Name
 	srcName     enc.Name
 	srcStr      string
-	srcSeq      uint64
+	leaveName   enc.Name
+	statusName  enc.Name
+	intCfg      *ndn.InterestConfig
+	datCfg      *ndn.DataConfig
+	signer      ndn.Signer
+	checker     *dataChecker
 	logger      *log.Entry
+	metrics     *healthMetrics
 	handleData  *healthHandlerData
+	isListening bool
 }
 
 func newHealthSync(app *eng.Engine, config *HealthConfig, constants *Constants) *healthSync {
 	var s *healthSync
 	logger := log.WithField("module", "svs")
 	syncPrefix := append(config.GroupPrefix, constants.SyncComponent)
+	leaveName := append(append(enc.Name{}, config.Source...), constants.LeaveComponent)
 
+	// Entries of the node may first be learned from peers, after a restart or before its first beat.
 	coreConfig := &TwoStateCoreConfig{
 		SyncPrefix:           syncPrefix,
+		SelfDatasets:         []enc.Name{config.Source, leaveName},
 		FormalEncoding:       config.FormalEncoding,
+		CompressedEncoding:   config.CompressedEncoding,
 		EfficientSuppression: config.EfficientSuppression,
+		PartialVector:        config.PartialVector,
+		Signer:               config.SyncSigner,
+		Validator:            config.SyncValidator,
+		Metrics:              renamedMetrics(config.Metrics, "svs_health_"),
+		EventSink:            config.EventSink,
 	}
 	s = &healthSync{
 		app:         app,
@@ -44,7 +66,24 @@ func newHealthSync(app *eng.Engine, config *HealthConfig, constants *Constants)
 		groupPrefix: config.GroupPrefix,
 		srcName:     config.Source,
 		srcStr:      config.Source.String(),
-		logger:      logger,
+		leaveName:   leaveName,
+		statusName:  statusName(config.Source, config.GroupPrefix, constants.StatusComponent),
+		intCfg: &ndn.InterestConfig{
+			MustBeFresh: true,
+			CanBePrefix: true,
+			Lifetime:    utl.IdPtr(constants.DataInterestLifeTime),
+		},
+		datCfg: &ndn.DataConfig{
+			ContentType: utl.IdPtr(ndn.ContentTypeBlob),
+			Freshness:   utl.IdPtr(constants.HeartbeatRate),
+		},
+		signer:  config.StatusSigner,
+		checker: newDataChecker(config.StatusTrustPolicy, config.StatusValidator),
+		logger:  logger,
+		metrics: newHealthMetrics(config.Metrics),
+	}
+	if s.signer == nil {
+		s.signer = sec.NewSha256Signer()
 	}
 	s.missChan = s.core.Subscribe()
 
@@ -57,21 +96,107 @@ func newHealthSync(app *eng.Engine, config *HealthConfig, constants *Constants)
 	return s
 }
 
-func (s *healthSync) Listen() {
-	s.core.Listen()
+func (s *healthSync) Listen(ctx context.Context) error {
+	if s.isListening {
+		return ErrAlreadyListening
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := s.app.AttachHandler(s.statusName, s.onStatusInterest)
+	if err != nil {
+		return fmt.Errorf("unable to register handler: %w", err)
+	}
+	err = registerRoute(ctx, s.app, s.statusName)
+	if err != nil {
+		s.app.DetachHandler(s.statusName)
+		return fmt.Errorf("unable to register route: %w", err)
+	}
+	err = s.core.Listen(ctx)
+	if err != nil {
+		s.app.DetachHandler(s.statusName)
+		unregisterRoute(ctx, s.app, s.statusName)
+		return err
+	}
+	s.isListening = true
+	s.logger.Info("Status-side Registered and Handled.")
+	return nil
 }
 
-func (s *healthSync) Activate(immediateStart bool) {
-	s.core.Activate(immediateStart)
+func (s *healthSync) Activate(ctx context.Context, immediateStart bool) error {
+	err := s.core.Activate(ctx, immediateStart)
+	if err != nil {
+		return err
+	}
 	s.logger.Info("Sync Activated.")
+	return nil
 }
 
-func (s *healthSync) Shutdown() {
-	s.core.Shutdown()
+// Peers are told about the departure before the Core stops.
+func (s *healthSync) Shutdown(ctx context.Context) error {
+	var errs []error
+	s.leave()
+	err := s.core.Shutdown(ctx)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if s.isListening {
+		err = s.app.DetachHandler(s.statusName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("detach handler error: %w", err))
+		}
+		err = unregisterRoute(ctx, s.app, s.statusName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unregister route error: %w", err))
+		}
+		s.isListening = false
+	}
 	if s.handleData != nil {
-		<-s.handleData.done
+		err = waitContext(ctx, s.handleData.done)
+		if err != nil {
+			errs = append(errs, err)
+		}
 	}
+	s.tracker.Close()
 	s.logger.Info("Sync Shutdown.")
+	return errors.Join(errs...)
+}
+
+// The payload is served to peers, versioned by the current heartbeat.
+func (s *healthSync) PublishStatus(payload []byte) {
+	s.tracker.SetPayload(s.srcStr, s.heartbeat(), payload)
+}
+
+// The fetched payload becomes available through the Tracker.
+func (s *healthSync) NeedStatus(node enc.Name) {
+	name := statusName(node, s.groupPrefix, s.constants.StatusComponent)
+	wire, _, finalName, err := s.app.Spec().MakeInterest(name, s.intCfg, nil, nil)
+	if err != nil {
+		s.logger.Errorf("Unable to make Interest: %+v", err)
+		return
+	}
+	err = s.app.Express(finalName, s.intCfg, wire,
+		func(result ndn.InterestResult, data ndn.Data, rawData, sigCovered enc.Wire, nackReason uint64) {
+			if result != ndn.InterestResultData {
+				s.logger.Warnf("Unable to fetch status of %s: %+v", node, result)
+				return
+			}
+			if !s.checker.check(node, data, sigCovered) {
+				s.logger.Warnf("Received unverifiable status %s", data.Name())
+				return
+			}
+			dataName := data.Name()
+			if len(dataName) != len(name)+1 || dataName[len(name)].Typ != enc.TypeVersionNameComponent {
+				s.logger.Warnf("Received unexpected status %s", dataName)
+				return
+			}
+			s.tracker.SetPayload(node.String(), dataName[len(name)].NumberVal(), data.Content().Join())
+		})
+	if err != nil {
+		s.logger.Errorf("Unable to send Interest: %+v", err)
+		return
+	}
+	s.metrics.pulls.Add(1)
 }
 
 func (s *healthSync) Tracker() Tracker {
@@ -82,26 +207,147 @@ func (s *healthSync) Core() Core {
 	return s.core
 }
 
+// The routine only wakes up for a heartbeat, an expiry, a status pull, or a SyncUpdate.
 func (s *healthSync) newHandling(data *healthHandlerData) {
 	go func() {
+		var (
+			clock  = s.constants.Clock
+			pull   <-chan time.Time
+			puller Timer
+			alive  int
+		)
+		// the gauge is changed rather than set, as HealthSyncs may share it
+		countAlive := func() {
+			n := len(s.tracker.Alive())
+			s.metrics.alive.Add(float64(n - alive))
+			alive = n
+		}
+		if s.constants.StatusPullRate > 0 {
+			puller = clock.NewTimer(s.constants.StatusPullRate)
+			defer puller.Stop()
+			pull = puller.C()
+		}
+		beat := clock.NewTimer(s.tracker.UntilBeat())
+		defer beat.Stop()
+		expiry := clock.NewTimer(0)
+		defer expiry.Stop()
 		for {
-			if s.tracker.UntilBeat() < s.constants.MonitorInterval {
-				s.core.Update(s.srcName, s.srcSeq)
-				s.tracker.Reset(s.srcStr)
-			}
-			s.tracker.Detect()
 			select {
 			case missing, ok := <-s.missChan:
 				if !ok {
-					data.done <- struct{}{}
+					s.metrics.alive.Add(-float64(alive))
+					close(data.done)
 					return
 				}
+				var leaves []MissingData
 				for _, m := range missing {
+					if l := len(m.Dataset); l > 0 && m.Dataset[l-1].Equal(s.constants.LeaveComponent) {
+						leaves = append(leaves, m)
+						continue
+					}
 					s.tracker.Reset(m.Dataset.String())
 				}
-			default:
+				// a leave older than the latest heartbeat of its node is from a previous run
+				for _, m := range leaves {
+					node := m.Dataset[:len(m.Dataset)-1].String()
+					if m.EndSeq >= s.seqno(node) {
+						s.tracker.Leave(node)
+					}
+				}
+				countAlive()
+				s.scheduleExpiry(expiry)
+			case <-beat.C():
+				s.bump(s.srcName)
+				s.metrics.heartbeats.Add(1)
+				s.tracker.Reset(s.srcStr)
+				beat.Reset(s.tracker.UntilBeat())
+			case <-expiry.C():
+				s.tracker.Detect()
+				countAlive()
+				s.scheduleExpiry(expiry)
+			case <-pull:
+				s.pullStatus()
+				puller.Reset(s.constants.StatusPullRate)
 			}
-			time.Sleep(s.constants.MonitorInterval)
 		}
 	}()
 }
+
+func (s *healthSync) scheduleExpiry(expiry Timer) {
+	if !expiry.Stop() {
+		select {
+		case <-expiry.C():
+		default:
+		}
+	}
+	if next, ok := s.tracker.NextExpiry(); ok {
+		expiry.Reset(next)
+	}
+}
+
+// Continues from the seqno known to the group, which survives restarts.
+func (s *healthSync) bump(dataset enc.Name) {
+	sv := s.core.StateVector()
+	sv.RLock()
+	seqno := sv.Get(dataset.String()) + 1
+	sv.RUnlock()
+	s.core.Update(dataset, seqno)
+}
+
+// The leave carries the last heartbeat, so peers can tell it apart from a later return.
+func (s *healthSync) leave() {
+	beat := s.heartbeat()
+	if beat > s.seqno(s.leaveName.String()) {
+		s.core.Update(s.leaveName, beat)
+	}
+}
+
+func (s *healthSync) heartbeat() uint64 {
+	return s.seqno(s.srcStr)
+}
+
+func (s *healthSync) seqno(dataset string) uint64 {
+	sv := s.core.StateVector()
+	sv.RLock()
+	defer sv.RUnlock()
+	return sv.Get(dataset)
+}
+
+func (s *healthSync) pullStatus() {
+	for _, m := range s.tracker.Alive() {
+		if m.Node == s.srcStr {
+			continue
+		}
+		node, err := enc.NameFromStr(m.Node)
+		if err == nil {
+			s.NeedStatus(node)
+		}
+	}
+}
+
+func (s *healthSync) onStatusInterest(interest ndn.Interest, rawInterest enc.Wire, sigCovered enc.Wire, reply ndn.ReplyFunc, deadline time.Time) {
+	payload := s.tracker.Payload(s.srcStr)
+	if payload == nil || len(interest.Name()) != len(s.statusName) {
+		return
+	}
+	name := make(enc.Name, len(s.statusName), len(s.statusName)+1)
+	copy(name, s.statusName)
+	name = append(name, enc.NewVersionComponent(s.heartbeat()))
+	wire, _, err := s.app.Spec().MakeData(name, s.datCfg, enc.Wire{payload}, s.signer)
+	if err != nil {
+		s.logger.Errorf("unable to encode status: %+v", err)
+		return
+	}
+	err = reply(wire)
+	if err != nil {
+		s.logger.Errorf("unable to reply with status: %+v", err)
+	}
+}
+
+// Returns <node>/<group>/<StatusComponent>.
+func statusName(node enc.Name, group enc.Name, comp enc.Component) enc.Name {
+	ret := make(enc.Name, 0, len(node)+len(group)+1)
+	ret = append(ret, node...)
+	ret = append(ret, group...)
+	return append(ret, comp)
+}
@@ -0,0 +1,52 @@
+package svs
+
+import (
+	leveldb "github.com/syndtr/goleveldb/leveldb"
+)
+
+type LevelDB struct {
+	handle *leveldb.DB
+}
+
+func NewLevelDB(path string) (LevelDB, error) {
+	path = resolvePath(path)
+	err := ensureDirectory(path)
+	if err != nil {
+		return LevelDB{nil}, err
+	}
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return LevelDB{nil}, err
+	}
+	return LevelDB{handle: db}, nil
+}
+
+func (fs LevelDB) Get(key []byte) []byte {
+	val, err := fs.handle.Get(key, nil)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+func (fs LevelDB) Set(key []byte, val []byte) error {
+	return fs.handle.Put(key, val, nil)
+}
+
+func (fs LevelDB) Remove(key []byte) error {
+	return fs.handle.Delete(key, nil)
+}
+
+func (fs LevelDB) ForEach(f func(key []byte, val []byte) bool) {
+	iter := fs.handle.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if !f(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+}
+
+func (fs LevelDB) Close() {
+	fs.handle.Close()
+}
@@ -0,0 +1,104 @@
+package svs
+
+import (
+	"sync"
+)
+
+// mailbox queues items for a single receiver and delivers them in order on its own
+// routine, so pushing never blocks. Once size items are queued, overflow decides what
+// is kept and reports whether an item was lost.
+type mailbox[T any] struct {
+	out      chan T
+	wake     chan struct{}
+	done     chan struct{}
+	mtx      sync.Mutex
+	queue    []T
+	size     int
+	overflow func([]T, T) ([]T, bool)
+	dropped  uint64
+	closed   bool
+}
+
+// A size of 0 never overflows.
+func newMailbox[T any](size int, overflow func([]T, T) ([]T, bool)) *mailbox[T] {
+	m := &mailbox[T]{
+		out:      make(chan T),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		queue:    make([]T, 0, size),
+		size:     size,
+		overflow: overflow,
+	}
+	go m.run()
+	return m
+}
+
+func (m *mailbox[T]) push(item T) {
+	m.mtx.Lock()
+	if m.closed {
+		m.mtx.Unlock()
+		return
+	}
+	if m.size > 0 && len(m.queue) >= m.size {
+		var lost bool
+		m.queue, lost = m.overflow(m.queue, item)
+		if lost {
+			m.dropped++
+		}
+	} else {
+		m.queue = append(m.queue, item)
+	}
+	m.mtx.Unlock()
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Items still queued are discarded and the out channel is closed.
+func (m *mailbox[T]) close() {
+	m.mtx.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.done)
+	}
+	m.mtx.Unlock()
+}
+
+func (m *mailbox[T]) lost() uint64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.dropped
+}
+
+func (m *mailbox[T]) run() {
+	var zero T
+	defer close(m.out)
+	for {
+		m.mtx.Lock()
+		if len(m.queue) == 0 {
+			m.mtx.Unlock()
+			select {
+			case <-m.wake:
+				continue
+			case <-m.done:
+				return
+			}
+		}
+		item := m.queue[0]
+		m.queue[0] = zero
+		m.queue = m.queue[1:]
+		m.mtx.Unlock()
+		select {
+		case m.out <- item:
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func dropOldest[T any](queue []T, item T) ([]T, bool) {
+	var zero T
+	queue[0] = zero
+	return append(queue[1:], item), true
+}
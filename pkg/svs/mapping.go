@@ -0,0 +1,248 @@
+package svs
+
+import (
+	"sync"
+
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+)
+
+// Mappings are kept apart from packets so they are never served as Data by themselves.
+func mappingKey(dataName enc.Name) []byte {
+	return append([]byte("svs-mapping"), dataName.Bytes()...)
+}
+
+// A mapping Interest is named <data name without seqno>/<MappingComponent>/<start>/<end>.
+func mappingName(dataName enc.Name, comp enc.Component, start uint64, end uint64) enc.Name {
+	ret := make(enc.Name, 0, len(dataName)+3)
+	ret = append(ret, dataName[:len(dataName)-1]...)
+	return append(ret, comp, enc.NewSequenceNumComponent(start), enc.NewSequenceNumComponent(end))
+}
+
+func parseMappingName(name enc.Name, comp enc.Component) (base enc.Name, start uint64, end uint64, ok bool) {
+	l := len(name)
+	if l < 3 || !name[l-3].Equal(comp) ||
+		name[l-2].Typ != enc.TypeSequenceNumNameComponent || name[l-1].Typ != enc.TypeSequenceNumNameComponent {
+		return nil, 0, 0, false
+	}
+	return name[:l-3], name[l-2].NumberVal(), name[l-1].NumberVal(), true
+}
+
+// Returns the stored mapping of up to limit seqnos from start, missing ones are left out.
+func loadMapping(storage Database, base enc.Name, start uint64, end uint64, limit uint) map[uint64]enc.Name {
+	ret := make(map[uint64]enc.Name)
+	if limit > 0 && end-start >= uint64(limit) {
+		end = start + uint64(limit) - 1
+	}
+	dataName := make(enc.Name, len(base), len(base)+1)
+	copy(dataName, base)
+	dataName = append(dataName, enc.Component{})
+	for seqno := start; seqno <= end && seqno >= start; seqno++ {
+		dataName[len(base)] = enc.NewSequenceNumComponent(seqno)
+		val := storage.Get(mappingKey(dataName))
+		if val == nil {
+			continue
+		}
+		name, err := enc.NameFromBytes(val)
+		if err == nil {
+			ret[seqno] = name
+		}
+	}
+	return ret
+}
+
+func encodeMapping(mapping map[uint64]enc.Name) enc.Wire {
+	var (
+		e, pos int
+		buf    []byte
+	)
+	for seqno, name := range mapping {
+		e = mappingEntryLength(seqno, name)
+		e += TypeMappingEntry.EncodingLength() + enc.TLNum(e).EncodingLength()
+		pos += e
+	}
+	buf = make([]byte, pos)
+	pos = 0
+	for seqno, name := range mapping {
+		pos += TypeMappingEntry.EncodeInto(buf[pos:])
+		pos += enc.TLNum(mappingEntryLength(seqno, name)).EncodeInto(buf[pos:])
+		// seqno
+		pos += TypeEntrySeqno.EncodeInto(buf[pos:])
+		pos += enc.TLNum(enc.Nat(seqno).EncodingLength()).EncodeInto(buf[pos:])
+		pos += enc.Nat(seqno).EncodeInto(buf[pos:])
+		// mapping
+		pos += enc.TypeName.EncodeInto(buf[pos:])
+		pos += enc.TLNum(name.EncodingLength()).EncodeInto(buf[pos:])
+		pos += name.EncodeInto(buf[pos:])
+	}
+	return enc.Wire{buf}
+}
+
+func mappingEntryLength(seqno uint64, name enc.Name) int {
+	e := TypeEntrySeqno.EncodingLength()
+	e += enc.TLNum(enc.Nat(seqno).EncodingLength()).EncodingLength()
+	e += enc.Nat(seqno).EncodingLength()
+	e += enc.TypeName.EncodingLength()
+	e += enc.TLNum(name.EncodingLength()).EncodingLength()
+	e += name.EncodingLength()
+	return e
+}
+
+func parseMapping(reader enc.ParseReader) (map[uint64]enc.Name, error) {
+	var (
+		name  enc.Name
+		seqno enc.Nat
+		l, t  enc.TLNum
+		b     enc.Buffer
+		err   error
+		ret   = make(map[uint64]enc.Name)
+	)
+	for reader.Pos() < reader.Length() {
+		// entry
+		t, err = enc.ReadTLNum(reader)
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		if t != TypeMappingEntry {
+			return ret, enc.ErrUnrecognizedField{TypeNum: t}
+		}
+		_, err = enc.ReadTLNum(reader)
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		// seqno
+		t, err = enc.ReadTLNum(reader)
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		if t != TypeEntrySeqno {
+			return ret, enc.ErrUnrecognizedField{TypeNum: t}
+		}
+		l, err = enc.ReadTLNum(reader)
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		if l != 1 && l != 2 && l != 4 && l != 8 {
+			return ret, enc.ErrFailToParse{TypeNum: t}
+		}
+		b, err = reader.ReadBuf(int(l))
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		seqno, _ = enc.ParseNat(b)
+		// mapping
+		t, err = enc.ReadTLNum(reader)
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		if t != enc.TypeName {
+			return ret, enc.ErrUnrecognizedField{TypeNum: t}
+		}
+		l, err = enc.ReadTLNum(reader)
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		if uint64(l) > uint64(reader.Length()-reader.Pos()) {
+			return ret, enc.ErrFailToParse{TypeNum: t}
+		}
+		name, err = enc.ReadName(reader.Delegate(int(l)))
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		ret[uint64(seqno)] = name
+	}
+	return ret, nil
+}
+
+// mappingFetch collects the mapping of a range in batches and reports once all of them finished.
+type mappingFetch struct {
+	app      *eng.Engine
+	intCfg   *ndn.InterestConfig
+	checker  *dataChecker
+	source   enc.Name
+	retries  uint
+	mapping  map[uint64]enc.Name
+	err      error
+	pending  int
+	mtx      sync.Mutex
+	callback func(map[uint64]enc.Name, error)
+}
+
+func (f *mappingFetch) start(names []enc.Name) {
+	if len(names) == 0 {
+		f.callback(f.mapping, nil)
+		return
+	}
+	f.pending = len(names)
+	for _, name := range names {
+		f.send(name, f.retries)
+	}
+}
+
+func (f *mappingFetch) send(name enc.Name, retries uint) {
+	wire, _, finalName, err := f.app.Spec().MakeInterest(name, f.intCfg, nil, nil)
+	if err != nil {
+		f.finish(nil, FetchError{Result: ndn.InterestResultError, Retries: f.retries - retries})
+		return
+	}
+	err = f.app.Express(finalName, f.intCfg, wire,
+		detached(func(result ndn.InterestResult, data ndn.Data, rawData, sigCovered enc.Wire, nackReason uint64) {
+			switch {
+			case result == ndn.InterestResultData:
+				if !f.checker.check(f.source, data, sigCovered) {
+					f.finish(nil, FetchError{Result: result, Retries: f.retries - retries})
+					return
+				}
+				mapping, err := parseMapping(enc.NewWireReader(data.Content()))
+				if err != nil {
+					f.finish(nil, err)
+					return
+				}
+				f.finish(mapping, nil)
+			case result == ndn.InterestResultNack || retries == 0:
+				f.finish(nil, FetchError{Result: result, NackReason: nackReason, Retries: f.retries - retries})
+			default:
+				f.send(name, retries-1)
+			}
+		}))
+	if err != nil {
+		f.finish(nil, FetchError{Result: ndn.InterestResultError, Retries: f.retries - retries})
+	}
+}
+
+func (f *mappingFetch) finish(mapping map[uint64]enc.Name, err error) {
+	f.mtx.Lock()
+	for seqno, name := range mapping {
+		f.mapping[seqno] = name
+	}
+	if err != nil && f.err == nil {
+		f.err = err
+	}
+	f.pending--
+	done := f.pending == 0
+	f.mtx.Unlock()
+	if done {
+		f.callback(f.mapping, f.err)
+	}
+}
+
+// Splits the range into mapping Interests of at most batch seqnos each.
+func mappingNames(dataName func(uint64) enc.Name, comp enc.Component, start uint64, end uint64, batch uint) []enc.Name {
+	var ret []enc.Name
+	if batch == 0 {
+		batch = 1
+	}
+	for start <= end {
+		last := start + uint64(batch) - 1
+		if last > end || last < start {
+			last = end
+		}
+		ret = append(ret, mappingName(dataName(start), comp, start, last))
+		if last == end {
+			break
+		}
+		start = last + 1
+	}
+	return ret
+}
@@ -0,0 +1,112 @@
+package svs
+
+import (
+	"bytes"
+	"container/list"
+	"sync"
+)
+
+type memoryEntry struct {
+	key string
+	val []byte
+}
+
+// MemoryDB keeps the most recently used entries in memory, evicting the least recently used
+// once capacity is exceeded. The Syncs' own entries, such as the stored seqno, state vector, and
+// mappings, are never evicted nor counted towards capacity.
+type MemoryDB struct {
+	entries  map[string]*list.Element
+	order    *list.List
+	kept     map[string][]byte
+	capacity int
+	mtx      sync.Mutex
+}
+
+// A capacity of 0 means unbounded.
+func NewMemoryDB(capacity int) *MemoryDB {
+	return &MemoryDB{
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		kept:     make(map[string][]byte),
+		capacity: capacity,
+	}
+}
+
+// Keys of the Syncs' own entries all start with this prefix.
+func isInternalKey(key []byte) bool {
+	return bytes.HasPrefix(key, []byte("svs-"))
+}
+
+func (db *MemoryDB) Get(key []byte) []byte {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	if isInternalKey(key) {
+		return db.kept[string(key)]
+	}
+	e, ok := db.entries[string(key)]
+	if !ok {
+		return nil
+	}
+	db.order.MoveToFront(e)
+	return e.Value.(*memoryEntry).val
+}
+
+func (db *MemoryDB) Set(key []byte, val []byte) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	cp := make([]byte, len(val))
+	copy(cp, val)
+	if isInternalKey(key) {
+		db.kept[string(key)] = cp
+		return nil
+	}
+	if e, ok := db.entries[string(key)]; ok {
+		e.Value.(*memoryEntry).val = cp
+		db.order.MoveToFront(e)
+		return nil
+	}
+	db.entries[string(key)] = db.order.PushFront(&memoryEntry{key: string(key), val: cp})
+	if db.capacity > 0 && db.order.Len() > db.capacity {
+		last := db.order.Back()
+		db.order.Remove(last)
+		delete(db.entries, last.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+func (db *MemoryDB) Remove(key []byte) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	delete(db.kept, string(key))
+	if e, ok := db.entries[string(key)]; ok {
+		db.order.Remove(e)
+		delete(db.entries, string(key))
+	}
+	return nil
+}
+
+// Iterates over a snapshot, from most to least recently used and then over the Syncs' own entries.
+func (db *MemoryDB) ForEach(f func(key []byte, val []byte) bool) {
+	db.mtx.Lock()
+	snapshot := make([]*memoryEntry, 0, db.order.Len()+len(db.kept))
+	for e := db.order.Front(); e != nil; e = e.Next() {
+		snapshot = append(snapshot, e.Value.(*memoryEntry))
+	}
+	for key, val := range db.kept {
+		snapshot = append(snapshot, &memoryEntry{key: key, val: val})
+	}
+	db.mtx.Unlock()
+	for _, e := range snapshot {
+		if !f([]byte(e.key), e.val) {
+			return
+		}
+	}
+}
+
+func (db *MemoryDB) Len() int {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	return db.order.Len() + len(db.kept)
+}
+
+func (db *MemoryDB) Close() {}
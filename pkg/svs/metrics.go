@@ -0,0 +1,146 @@
+package svs
+
+import "strings"
+
+// Metrics creates the instruments Cores and Syncs report to. Asking twice for the same
+// name returns the same instrument, so Syncs sharing one Metrics add up into the same series,
+// gauges included. The Core of a HealthSync reports under svs_health_ instead of svs_.
+type Metrics interface {
+	Counter(name string, help string) Counter
+	Gauge(name string, help string) Gauge
+	Histogram(name string, help string) Histogram
+}
+
+type Counter interface {
+	Add(float64)
+}
+
+type Gauge interface {
+	Set(float64)
+	Add(float64)
+}
+
+type Histogram interface {
+	Observe(float64)
+}
+
+type nullMetrics struct{}
+
+type nullInstrument struct{}
+
+func (nullMetrics) Counter(string, string) Counter     { return nullInstrument{} }
+func (nullMetrics) Gauge(string, string) Gauge         { return nullInstrument{} }
+func (nullMetrics) Histogram(string, string) Histogram { return nullInstrument{} }
+
+func (nullInstrument) Add(float64)     {}
+func (nullInstrument) Set(float64)     {}
+func (nullInstrument) Observe(float64) {}
+
+func metricsOrNull(m Metrics) Metrics {
+	if m == nil {
+		return nullMetrics{}
+	}
+	return m
+}
+
+type prefixedMetrics struct {
+	Metrics
+	prefix string
+}
+
+// Replaces the svs_ prefix of every instrument name, keeping an internal Core apart from the Sync's.
+func renamedMetrics(m Metrics, prefix string) Metrics {
+	if m == nil {
+		return nil
+	}
+	return prefixedMetrics{Metrics: m, prefix: prefix}
+}
+
+func (m prefixedMetrics) Counter(name string, help string) Counter {
+	return m.Metrics.Counter(m.rename(name), help)
+}
+
+func (m prefixedMetrics) Gauge(name string, help string) Gauge {
+	return m.Metrics.Gauge(m.rename(name), help)
+}
+
+func (m prefixedMetrics) Histogram(name string, help string) Histogram {
+	return m.Metrics.Histogram(m.rename(name), help)
+}
+
+func (m prefixedMetrics) rename(name string) string {
+	return m.prefix + strings.TrimPrefix(name, "svs_")
+}
+
+type coreMetrics struct {
+	sent       Counter
+	suppressed Counter
+	received   Counter
+	invalid    Counter
+	unparsable Counter
+	missing    Counter
+	shaking    Counter
+}
+
+func newCoreMetrics(m Metrics) *coreMetrics {
+	m = metricsOrNull(m)
+	return &coreMetrics{
+		sent:       m.Counter("svs_sync_interests_sent_total", "Sync Interests sent."),
+		suppressed: m.Counter("svs_sync_interests_suppressed_total", "Sync Interests not sent as the group was already up to date."),
+		received:   m.Counter("svs_sync_interests_received_total", "Sync Interests received."),
+		invalid:    m.Counter("svs_sync_interests_invalid_total", "Sync Interests that failed validation."),
+		unparsable: m.Counter("svs_state_vectors_unparsable_total", "State vectors that could not be parsed."),
+		missing:    m.Counter("svs_missing_publications_total", "Publications found missing in received state vectors."),
+		shaking:    m.Counter("svs_shaking_entered_total", "Bursts of inconsistent state vectors that started shaking."),
+	}
+}
+
+func (m *coreMetrics) found(missing SyncUpdate) {
+	var n uint64
+	for _, d := range missing {
+		n += d.EndSeq - d.StartSeq + 1
+	}
+	m.missing.Add(float64(n))
+}
+
+type syncMetrics struct {
+	interests Counter
+	retries   Counter
+	fetched   Counter
+	failures  Counter
+	invalid   Counter
+	served    Counter
+	queued    Gauge
+	fetches   Gauge
+	latency   Histogram
+}
+
+func newSyncMetrics(m Metrics) *syncMetrics {
+	m = metricsOrNull(m)
+	return &syncMetrics{
+		interests: m.Counter("svs_data_interests_sent_total", "Data Interests sent, including retries and segments."),
+		retries:   m.Counter("svs_data_interest_retries_total", "Data Interests sent again after a timeout."),
+		fetched:   m.Counter("svs_publications_fetched_total", "Publications fetched and handed to the DataCallback."),
+		failures:  m.Counter("svs_publication_fetch_failures_total", "Publications that could not be fetched."),
+		invalid:   m.Counter("svs_publications_invalid_total", "Fetched Data that failed validation."),
+		served:    m.Counter("svs_data_served_total", "Data Interests answered from storage."),
+		queued:    m.Gauge("svs_fetch_queue_depth", "Publications waiting to be fetched."),
+		fetches:   m.Gauge("svs_fetches_outstanding", "Publications being fetched."),
+		latency:   m.Histogram("svs_fetch_duration_seconds", "Time from the first Data Interest of a publication until it was fetched."),
+	}
+}
+
+type healthMetrics struct {
+	heartbeats Counter
+	pulls      Counter
+	alive      Gauge
+}
+
+func newHealthMetrics(m Metrics) *healthMetrics {
+	m = metricsOrNull(m)
+	return &healthMetrics{
+		heartbeats: m.Counter("svs_heartbeats_sent_total", "Heartbeats published by HealthSync."),
+		pulls:      m.Counter("svs_status_pulls_total", "Status Interests sent by HealthSync."),
+		alive:      m.Gauge("svs_alive_nodes", "Nodes HealthSync currently considers renewed."),
+	}
+}
@@ -1,7 +1,10 @@
 package svs
 
 import (
-	"sync/atomic"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	log "github.com/apex/log"
@@ -16,6 +19,8 @@ type nativeFetchItem struct {
 	source  enc.Name
 	seqno   uint64
 	retries uint
+	segs    segmentFetch
+	started time.Time
 }
 
 type nativeHandlerData struct {
@@ -23,26 +28,37 @@ type nativeHandlerData struct {
 }
 
 type nativeSync struct {
-	app          *eng.Engine
-	core         Core
-	constants    *Constants
-	missChan     chan SyncUpdate
-	namingScheme NamingScheme
-	groupPrefix  enc.Name
-	srcName      enc.Name
-	srcSeq       uint64
-	storage      Database
-	intCfg       *ndn.InterestConfig
-	datCfg       *ndn.DataConfig
-	logger       *log.Entry
-	dataCall     func(enc.Name, uint64, ndn.Data)
-	fetchQueue   chan *nativeFetchItem
-	handleData   *nativeHandlerData
-	numFetches   *int32
-	isListening  bool
+	app           *eng.Engine
+	core          Core
+	constants     *Constants
+	missChan      <-chan SyncUpdate
+	namingScheme  NamingScheme
+	groupPrefix   enc.Name
+	srcName       enc.Name
+	srcSeq        uint64
+	storage       Database
+	ownStorage    bool
+	compactor     *compactor
+	progress      *progress
+	intCfg        *ndn.InterestConfig
+	datCfg        *ndn.DataConfig
+	signer        ndn.Signer
+	checker       *dataChecker
+	logger        *log.Entry
+	dataCall      func(enc.Name, uint64, ndn.Data)
+	invalidCall   func(enc.Name, uint64, ndn.Data)
+	errorCall     func(enc.Name, uint64, FetchError)
+	calls         callQueue
+	mappingFilter func(enc.Name, uint64, enc.Name) bool
+	fetchPolicy   FetchPolicy
+	fetchMtx      sync.Mutex
+	handleData    *nativeHandlerData
+	numFetches    int
+	metrics       *syncMetrics
+	isListening   bool
 }
 
-func newNativeSync(app *eng.Engine, config *NativeConfig, constants *Constants) *nativeSync {
+func newNativeSync(app *eng.Engine, config *NativeConfig, constants *Constants) (*nativeSync, error) {
 	var s *nativeSync
 	logger := log.WithField("module", "svs")
 	syncPrefix := config.GroupPrefix
@@ -50,15 +66,41 @@ func newNativeSync(app *eng.Engine, config *NativeConfig, constants *Constants)
 		syncPrefix = append(syncPrefix, constants.SyncComponent)
 	}
 
+	if config.DataCallback == nil {
+		return nil, ErrMissingCallback
+	}
+	storage, ownStorage := config.Storage, false
+	if storage == nil {
+		bolt, err := NewBoltDB(config.StoragePath, []byte("svs-packets"))
+		if err != nil {
+			return nil, fmt.Errorf("unable to create storage: %w", err)
+		}
+		storage, ownStorage = bolt, true
+	}
+	initial, srcSeq, err := loadState(storage)
+	if err != nil {
+		if ownStorage {
+			storage.Close()
+		}
+		return nil, fmt.Errorf("unable to restore state: %w", err)
+	}
+	if initial != nil && initial.Get(config.Source.String()) > srcSeq {
+		srcSeq = initial.Get(config.Source.String())
+	}
 	coreConfig := &TwoStateCoreConfig{
 		SyncPrefix:           syncPrefix,
 		FormalEncoding:       config.FormalEncoding,
+		CompressedEncoding:   config.CompressedEncoding,
 		EfficientSuppression: config.EfficientSuppression,
+		PartialVector:        config.PartialVector,
+		Signer:               config.SyncSigner,
+		Validator:            config.SyncValidator,
+		InitialState:         initial,
+		Metrics:              config.Metrics,
+		EventSink:            config.EventSink,
 	}
-	storage, err := NewBoltDB(config.StoragePath, []byte("svs-packets"))
-	if err != nil {
-		logger.Errorf("Unable to create storage: %+v", err)
-		return nil
+	if srcSeq > 0 {
+		coreConfig.SelfDatasets = []enc.Name{config.Source}
 	}
 	s = &nativeSync{
 		app:          app,
@@ -67,7 +109,9 @@ func newNativeSync(app *eng.Engine, config *NativeConfig, constants *Constants)
 		namingScheme: config.NamingScheme,
 		groupPrefix:  config.GroupPrefix,
 		srcName:      config.Source,
+		srcSeq:       srcSeq,
 		storage:      storage,
+		ownStorage:   ownStorage,
 		intCfg: &ndn.InterestConfig{
 			MustBeFresh: true,
 			CanBePrefix: true,
@@ -77,18 +121,33 @@ func newNativeSync(app *eng.Engine, config *NativeConfig, constants *Constants)
 			ContentType: utl.IdPtr(ndn.ContentTypeBlob),
 			Freshness:   utl.IdPtr(constants.DataPacketFreshness),
 		},
-		logger:     logger,
-		dataCall:   config.DataCallback,
-		fetchQueue: make(chan *nativeFetchItem, constants.InitialFetchQueueSize),
-		numFetches: new(int32),
+		signer:        config.DataSigner,
+		checker:       newDataChecker(config.TrustPolicy, config.DataValidator),
+		logger:        logger,
+		metrics:       newSyncMetrics(config.Metrics),
+		dataCall:      config.DataCallback,
+		invalidCall:   config.InvalidCallback,
+		errorCall:     config.ErrorCallback,
+		mappingFilter: config.MappingFilter,
+		fetchPolicy:   config.FetchPolicy,
+	}
+	if s.signer == nil {
+		s.signer = sec.NewSha256Signer()
+	}
+	if s.fetchPolicy == nil {
+		s.fetchPolicy = newFifoFetchPolicy(int(constants.MaxConcurrentDataInterests), constants.InitialFetchQueueSize)
+	}
+	if config.Retention != nil {
+		s.compactor = newCompactor(storage, config.Retention, constants.Clock, config.EvictCallback)
 	}
-	s.missChan = s.core.Subscribe()
 
 	hData := &nativeHandlerData{
 		done: make(chan struct{}),
 	}
 	if config.HandlingOption != NoHandling {
+		s.missChan = s.core.Subscribe()
 		s.handleData = hData
+		s.progress = newProgress(initial)
 	}
 	switch config.HandlingOption {
 	case SourceCentricHandling:
@@ -98,64 +157,86 @@ func newNativeSync(app *eng.Engine, config *NativeConfig, constants *Constants)
 	default:
 	}
 
-	return s
+	return s, nil
 }
 
-func (s *nativeSync) Listen() {
-	dataPrefix := s.groupPrefix
-	if s.namingScheme != BareSourceOrientedNaming {
-		dataPrefix = append(dataPrefix, s.constants.DataComponent)
+func (s *nativeSync) Listen(ctx context.Context) error {
+	if s.isListening {
+		return ErrAlreadyListening
 	}
-	if s.namingScheme == GroupOrientedNaming {
-		dataPrefix = append(dataPrefix, s.srcName...)
-	} else {
-		dataPrefix = append(s.srcName, dataPrefix...)
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	dataPrefix := s.getDataPrefix()
 	err := s.app.AttachHandler(dataPrefix, s.onInterest)
 	if err != nil {
-		s.logger.Errorf("Unable to register handler: %+v", err)
-		return
+		return fmt.Errorf("unable to register handler: %w", err)
 	}
-	err = s.app.RegisterRoute(dataPrefix)
+	err = registerRoute(ctx, s.app, dataPrefix)
 	if err != nil {
-		s.logger.Errorf("Unable to register route: %+v", err)
-		return
+		s.app.DetachHandler(dataPrefix)
+		return fmt.Errorf("unable to register route: %w", err)
+	}
+	err = s.core.Listen(ctx)
+	if err != nil {
+		s.app.DetachHandler(dataPrefix)
+		unregisterRoute(ctx, s.app, dataPrefix)
+		return err
 	}
 	s.isListening = true
 	s.logger.Info("Data-side Registered and Handled.")
-	s.core.Listen()
+	return nil
 }
 
-func (s *nativeSync) Activate(immediateStart bool) {
-	s.core.Activate(immediateStart)
+func (s *nativeSync) Activate(ctx context.Context, immediateStart bool) error {
+	err := s.core.Activate(ctx, immediateStart)
+	if err != nil {
+		return err
+	}
 	s.logger.Info("Sync Activated.")
+	return nil
 }
 
-func (s *nativeSync) Shutdown() {
-	s.core.Shutdown()
+func (s *nativeSync) Shutdown(ctx context.Context) error {
+	var errs []error
+	err := s.core.Shutdown(ctx)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	err = storeStateVector(s.storage, s.progress.limit(s.core.StateVector()))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("unable to persist state vector: %w", err))
+	}
 	if s.isListening {
-		dataPrefix := s.groupPrefix
-		if s.namingScheme != BareSourceOrientedNaming {
-			dataPrefix = append(dataPrefix, s.constants.DataComponent)
-		}
-		if s.namingScheme == GroupOrientedNaming {
-			dataPrefix = append(dataPrefix, s.srcName...)
-		} else {
-			dataPrefix = append(s.srcName, dataPrefix...)
-		}
-		err := s.app.DetachHandler(dataPrefix)
+		dataPrefix := s.getDataPrefix()
+		err = s.app.DetachHandler(dataPrefix)
 		if err != nil {
-			s.logger.Errorf("Detech handler error: %+v", err)
+			errs = append(errs, fmt.Errorf("detach handler error: %w", err))
 		}
-		err = s.app.UnregisterRoute(dataPrefix)
+		err = unregisterRoute(ctx, s.app, dataPrefix)
 		if err != nil {
-			s.logger.Errorf("Unregister route error: %+v", err)
+			errs = append(errs, fmt.Errorf("unregister route error: %w", err))
 		}
+		s.isListening = false
 	}
+	handled := true
 	if s.handleData != nil {
-		<-s.handleData.done
+		err = waitContext(ctx, s.handleData.done)
+		if err != nil {
+			errs = append(errs, err)
+			handled = false
+		}
+	}
+	if s.compactor != nil {
+		s.compactor.stop()
+	}
+	// Storage is left open while the handling routine may still write to it.
+	if s.ownStorage && handled {
+		s.storage.Close()
+		s.ownStorage = false
 	}
 	s.logger.Info("Sync Shutdown.")
+	return errors.Join(errs...)
 }
 
 func (s *nativeSync) NeedData(source enc.Name, seqno uint64) {
@@ -164,34 +245,64 @@ func (s *nativeSync) NeedData(source enc.Name, seqno uint64) {
 		seqno:   seqno,
 		retries: s.constants.DataInterestRetries,
 	}
-	if s.constants.MaxConcurrentDataInterests == 0 || atomic.LoadInt32(s.numFetches) < s.constants.MaxConcurrentDataInterests {
-		atomic.AddInt32(s.numFetches, 1)
-		s.sendInterest(i)
-		return
-	}
-	s.fetchQueue <- i
+	s.fetchMtx.Lock()
+	s.fetchPolicy.Push(FetchItem{Source: source, Seqno: seqno, ref: i})
+	s.metrics.queued.Add(1)
+	s.fetchMtx.Unlock()
+	s.processQueue()
+}
+
+func (s *nativeSync) PublishData(content []byte) (uint64, error) {
+	_, seqno, err := s.PublishDataWithName(content)
+	return seqno, err
+}
+
+// Returns the name of the publication, segments are named under it.
+func (s *nativeSync) PublishDataWithName(content []byte) (enc.Name, uint64, error) {
+	return s.publish(content, nil)
 }
 
-func (s *nativeSync) PublishData(content []byte) {
-	s.srcSeq++
-	name := s.getDataName(s.srcName, s.srcSeq)
-	wire, _, err := s.app.Spec().MakeData(
-		name,
-		s.datCfg,
-		enc.Wire{content},
-		sec.NewSha256Signer())
+// The mapping is served to nodes deciding whether to fetch the publication.
+func (s *nativeSync) PublishDataWithMapping(content []byte, mapping enc.Name) (uint64, error) {
+	_, seqno, err := s.publish(content, mapping)
+	return seqno, err
+}
+
+func (s *nativeSync) publish(content []byte, mapping enc.Name) (enc.Name, uint64, error) {
+	seqno := s.srcSeq + 1
+	name := s.getDataName(s.srcName, seqno)
+	names, wires, err := makePublication(s.app.Spec(), name, s.datCfg, s.signer, content, s.constants.MaxSegmentSize, s.constants.MaxSegments)
 	if err != nil {
-		s.logger.Errorf("unable to encode data: %+v", err)
-		return
+		return nil, 0, fmt.Errorf("unable to encode data: %w", err)
 	}
-	bytes := wire.Join()
-	if len(bytes) > 8800 {
-		s.logger.Warn("publication too large to be published")
-		return
+	for i := range names {
+		err = s.storage.Set(names[i].Bytes(), wires[i])
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to store data: %w", err)
+		}
+		if s.compactor != nil {
+			s.compactor.track(names[i], len(wires[i]))
+		}
+	}
+	if mapping != nil {
+		err = s.storage.Set(mappingKey(name), mapping.Bytes())
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to store mapping: %w", err)
+		}
 	}
 	s.logger.Info("Publishing data " + name.String())
-	s.storage.Set(name.Bytes(), bytes)
-	s.core.Update(s.srcName, s.srcSeq)
+	s.srcSeq = seqno
+	err = storeSequence(s.storage, seqno)
+	if err != nil {
+		s.logger.Errorf("unable to persist seqno: %+v", err)
+	}
+	s.progress.done(s.srcName.String(), seqno)
+	s.core.Update(s.srcName, seqno)
+	err = storeStateVector(s.storage, s.progress.limit(s.core.StateVector()))
+	if err != nil {
+		s.logger.Errorf("unable to persist state vector: %+v", err)
+	}
+	return name, seqno, nil
 }
 
 func (s *nativeSync) FeedInterest(interest ndn.Interest, rawInterest enc.Wire, sigCovered enc.Wire, reply ndn.ReplyFunc, deadline time.Time) {
@@ -203,42 +314,138 @@ func (s *nativeSync) Core() Core {
 }
 
 func (s *nativeSync) sendInterest(item *nativeFetchItem) {
-	wire, _, finalName, err := s.app.Spec().MakeInterest(s.getDataName(item.source, item.seqno), s.intCfg, nil, nil)
+	wire, _, finalName, err := s.app.Spec().MakeInterest(item.segs.interestName(s.getDataName(item.source, item.seqno)), s.intCfg, nil, nil)
 	if err != nil {
 		s.logger.Errorf("Unable to make Interest: %+v", err)
+		s.fetchFailed(item, ndn.InterestResultError, 0)
+		s.fetchDone(item)
 		return
 	}
 	err = s.app.Express(finalName, s.intCfg, wire,
-		func(result ndn.InterestResult, data ndn.Data, rawData, sigCovered enc.Wire, nackReason uint64) {
-			if result == ndn.InterestResultData || result == ndn.InterestResultNack || item.retries == 0 {
-				s.dataCall(item.source, item.seqno, data)
-				atomic.AddInt32(s.numFetches, -1)
-				s.processQueue()
-			} else {
+		detached(func(result ndn.InterestResult, data ndn.Data, rawData, sigCovered enc.Wire, nackReason uint64) {
+			s.fetchFeedback(item, result)
+			switch {
+			case result == ndn.InterestResultData:
+				if !s.checker.check(item.source, data, sigCovered) {
+					s.logger.Warnf("Received unverifiable data %s", finalName)
+					s.metrics.invalid.Add(1)
+					if s.invalidCall != nil {
+						s.calls.run(func() { s.invalidCall(item.source, item.seqno, data) })
+					}
+					break
+				}
+				done, err := item.segs.add(data, s.constants.MaxSegments)
+				if err != nil {
+					s.logger.Warnf("Received unexpected segment %s: %+v", data.Name(), err)
+					s.metrics.invalid.Add(1)
+					if s.invalidCall != nil {
+						s.calls.run(func() { s.invalidCall(item.source, item.seqno, data) })
+					}
+					break
+				}
+				if !done {
+					item.retries = s.constants.DataInterestRetries
+					s.sendInterest(item)
+					return
+				}
+				s.metrics.fetched.Add(1)
+				s.metrics.latency.Observe(s.constants.Clock.Now().Sub(item.started).Seconds())
+				data = item.segs.data(s.getDataName(item.source, item.seqno))
+				s.calls.run(func() { s.dataCall(item.source, item.seqno, data) })
+			case result == ndn.InterestResultNack || item.retries == 0:
+				s.fetchFailed(item, result, nackReason)
+			default:
 				item.retries--
+				s.metrics.retries.Add(1)
 				s.sendInterest(item)
+				return
 			}
-		})
+			s.fetchDone(item)
+		}))
 	if err != nil {
 		s.logger.Errorf("Unable to send Interest: %+v", err)
+		s.fetchFailed(item, ndn.InterestResultError, 0)
+		s.fetchDone(item)
 		return
 	}
+	s.metrics.interests.Add(1)
+}
+
+func (s *nativeSync) fetchFailed(item *nativeFetchItem, result ndn.InterestResult, nackReason uint64) {
+	ferr := FetchError{
+		Result:     result,
+		NackReason: nackReason,
+		Retries:    s.constants.DataInterestRetries - item.retries,
+	}
+	s.metrics.failures.Add(1)
+	if s.errorCall != nil {
+		s.calls.run(func() { s.errorCall(item.source, item.seqno, ferr) })
+	} else {
+		s.logger.Warnf("Unable to fetch %s:%d: %+v", item.source, item.seqno, ferr)
+	}
 }
 
 func (s *nativeSync) processQueue() {
-	if s.constants.MaxConcurrentDataInterests == 0 || atomic.LoadInt32(s.numFetches) < s.constants.MaxConcurrentDataInterests {
-		select {
-		case f := <-s.fetchQueue:
-			atomic.AddInt32(s.numFetches, 1)
-			s.sendInterest(f)
-			return
-		default:
+	var (
+		ready   []*nativeFetchItem
+		dropped int
+	)
+	s.fetchMtx.Lock()
+	for w := s.fetchPolicy.Window(); w <= 0 || s.numFetches < w; w = s.fetchPolicy.Window() {
+		f, ok := s.fetchPolicy.Pop()
+		if !ok {
+			break
+		}
+		item, ok := f.ref.(*nativeFetchItem)
+		if !ok {
+			s.logger.Errorf("FetchPolicy returned an item it was not given: %s:%d", f.Source, f.Seqno)
+			dropped++
+			continue
 		}
+		s.numFetches++
+		ready = append(ready, item)
 	}
+	// gauges are changed rather than set, as Syncs may share them
+	s.metrics.queued.Add(-float64(len(ready) + dropped))
+	s.metrics.fetches.Add(float64(len(ready)))
+	s.fetchMtx.Unlock()
+	now := s.constants.Clock.Now()
+	for _, item := range ready {
+		item.started = now
+		s.sendInterest(item)
+	}
+}
+
+func (s *nativeSync) fetchDone(item *nativeFetchItem) {
+	s.progress.done(item.source.String(), item.seqno)
+	s.fetchMtx.Lock()
+	s.numFetches--
+	s.metrics.fetches.Add(-1)
+	s.fetchMtx.Unlock()
+	s.processQueue()
+}
+
+func (s *nativeSync) fetchFeedback(item *nativeFetchItem, result ndn.InterestResult) {
+	f := FetchItem{Source: item.source, Seqno: item.seqno, ref: item}
+	s.fetchMtx.Lock()
+	switch result {
+	case ndn.InterestResultData:
+		s.fetchPolicy.OnData(f)
+	case ndn.InterestResultNack, ndn.InterestResultTimeout:
+		s.fetchPolicy.OnTimeout(f)
+	}
+	s.fetchMtx.Unlock()
 }
 
 func (s *nativeSync) onInterest(interest ndn.Interest, rawInterest enc.Wire, sigCovered enc.Wire, reply ndn.ReplyFunc, deadline time.Time) {
+	if base, start, end, ok := parseMappingName(interest.Name(), s.constants.MappingComponent); ok {
+		s.onMappingInterest(interest.Name(), base, start, end, reply)
+		return
+	}
 	dataPkt := s.storage.Get(interest.Name().Bytes())
+	if dataPkt == nil && interest.CanBePrefix() {
+		dataPkt = s.storage.Get(segmentName(interest.Name(), 0).Bytes())
+	}
 	if dataPkt != nil {
 		s.logger.Info("Serving data " + interest.Name().String())
 		err := reply(enc.Wire{dataPkt})
@@ -246,9 +453,67 @@ func (s *nativeSync) onInterest(interest ndn.Interest, rawInterest enc.Wire, sig
 			s.logger.Errorf("unable to reply with data: %+v", err)
 			return
 		}
+		s.metrics.served.Add(1)
 	}
 }
 
+func (s *nativeSync) getDataPrefix() enc.Name {
+	dataPrefix := s.groupPrefix
+	if s.namingScheme != BareSourceOrientedNaming {
+		dataPrefix = append(dataPrefix, s.constants.DataComponent)
+	}
+	if s.namingScheme == GroupOrientedNaming {
+		dataPrefix = append(dataPrefix, s.srcName...)
+	} else {
+		dataPrefix = append(s.srcName, dataPrefix...)
+	}
+	return dataPrefix
+}
+
+func (s *nativeSync) onMappingInterest(name enc.Name, base enc.Name, start uint64, end uint64, reply ndn.ReplyFunc) {
+	mapping := loadMapping(s.storage, base, start, end, s.constants.MappingBatchSize)
+	wire, _, err := s.app.Spec().MakeData(name, s.datCfg, encodeMapping(mapping), s.signer)
+	if err != nil {
+		s.logger.Errorf("unable to encode mapping: %+v", err)
+		return
+	}
+	err = reply(wire)
+	if err != nil {
+		s.logger.Errorf("unable to reply with mapping: %+v", err)
+	}
+}
+
+// The callback receives every mapping found within the range, seqnos without one are left out.
+func (s *nativeSync) FetchMapping(source enc.Name, start uint64, end uint64, callback func(map[uint64]enc.Name, error)) {
+	f := &mappingFetch{
+		app:      s.app,
+		intCfg:   s.intCfg,
+		checker:  s.checker,
+		source:   source,
+		retries:  s.constants.DataInterestRetries,
+		mapping:  make(map[uint64]enc.Name),
+		callback: callback,
+	}
+	dataName := func(seqno uint64) enc.Name { return s.getDataName(source, seqno) }
+	f.start(mappingNames(dataName, s.constants.MappingComponent, start, end, s.constants.MappingBatchSize))
+}
+
+// Only publications passing the MappingFilter are fetched, a nil mapping means none was found.
+func (s *nativeSync) needFiltered(m MissingData) {
+	s.FetchMapping(m.Dataset, m.StartSeq, m.EndSeq, func(mapping map[uint64]enc.Name, err error) {
+		if err != nil {
+			s.logger.Warnf("Unable to fetch mapping of %s: %+v", m.Dataset, err)
+		}
+		for seqno := m.StartSeq; seqno <= m.EndSeq && seqno >= m.StartSeq; seqno++ {
+			if s.mappingFilter(m.Dataset, seqno, mapping[seqno]) {
+				s.NeedData(m.Dataset, seqno)
+			} else {
+				s.progress.done(m.Dataset.String(), seqno)
+			}
+		}
+	})
+}
+
 func (s *nativeSync) getDataName(source enc.Name, seqno uint64) enc.Name {
 	dataName := s.groupPrefix
 	if s.namingScheme != BareSourceOrientedNaming {
@@ -269,10 +534,14 @@ func (s *nativeSync) newSourceCentricHandling(data *nativeHandlerData) {
 			select {
 			case missing, ok := <-s.missChan:
 				if !ok {
-					data.done <- struct{}{}
+					close(data.done)
 					return
 				}
 				for _, m := range missing {
+					if s.mappingFilter != nil {
+						s.needFiltered(m)
+						continue
+					}
 					for m.StartSeq <= m.EndSeq {
 						s.NeedData(m.Dataset, m.StartSeq)
 						m.StartSeq++
@@ -290,9 +559,15 @@ func (s *nativeSync) newEqualTrafficHandling(data *nativeHandlerData) {
 			select {
 			case missing, ok := <-s.missChan:
 				if !ok {
-					data.done <- struct{}{}
+					close(data.done)
 					return
 				}
+				if s.mappingFilter != nil {
+					for _, m := range missing {
+						s.needFiltered(m)
+					}
+					continue
+				}
 				for {
 					allFetched = true
 					for _, m := range missing {
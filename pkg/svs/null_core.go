@@ -1,6 +1,7 @@
 package svs
 
 import (
+	"context"
 	"time"
 
 	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
@@ -9,12 +10,13 @@ import (
 
 type nullCore struct{}
 
-func newNullCore() *nullCore                              { return &nullCore{} }
-func (c *nullCore) Listen()                               {}
-func (c *nullCore) Activate(immediateStart bool)          {}
-func (c *nullCore) Shutdown()                             {}
-func (c *nullCore) Update(dataset enc.Name, seqno uint64) {}
-func (c *nullCore) Subscribe() chan SyncUpdate            { return nil }
-func (c *nullCore) StateVector() *StateVector             { return NewStateVector() }
+func newNullCore() *nullCore                                                { return &nullCore{} }
+func (c *nullCore) Listen(ctx context.Context) error                        { return nil }
+func (c *nullCore) Activate(ctx context.Context, immediateStart bool) error { return nil }
+func (c *nullCore) Shutdown(ctx context.Context) error                      { return nil }
+func (c *nullCore) Update(dataset enc.Name, seqno uint64)                   {}
+func (c *nullCore) Subscribe() <-chan SyncUpdate                            { return nil }
+func (c *nullCore) Unsubscribe(ch <-chan SyncUpdate)                        {}
+func (c *nullCore) StateVector() *StateVector                               { return NewStateVector() }
 func (c *nullCore) FeedInterest(interest ndn.Interest, rawInterest enc.Wire, sigCovered enc.Wire, reply ndn.ReplyFunc, deadline time.Time) {
 }
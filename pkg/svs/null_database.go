@@ -0,0 +1,10 @@
+package svs
+
+type NullDB struct{}
+
+func NewNullDB() NullDB                                       { return NullDB{} }
+func (db NullDB) Get(key []byte) []byte                       { return nil }
+func (db NullDB) Set(key []byte, val []byte) error            { return nil }
+func (db NullDB) Remove(key []byte) error                     { return nil }
+func (db NullDB) ForEach(f func(key []byte, val []byte) bool) {}
+func (db NullDB) Close()                                      {}
@@ -1,92 +1,130 @@
 package svs
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"slices"
+	"sync/atomic"
 	"time"
 
 	log "github.com/apex/log"
 	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
 	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
 	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
-	sec "github.com/zjkmxy/go-ndn/pkg/security"
 	utl "github.com/zjkmxy/go-ndn/pkg/utils"
 )
 
 type oneStateCore struct {
 	app         *eng.Engine
 	constants   *Constants
-	subs        []chan SyncUpdate
+	subs        fanout
 	syncPrefix  enc.Name
 	selfsets    []string
 	local       *StateVector
 	scheduler   Scheduler
 	logger      *log.Entry
+	metrics     *coreMetrics
+	events      eventEmitter
 	intCfg      *ndn.InterestConfig
+	signer      ndn.Signer
+	validator   Validator
 	formal      bool
+	compressed  bool
+	partial     bool
+	rotation    int
 	isListening bool
-	isActive    bool
+	isActive    atomic.Bool
 }
 
 func newOneStateCore(app *eng.Engine, config *OneStateCoreConfig, constants *Constants) *oneStateCore {
 	c := &oneStateCore{
 		app:        app,
 		constants:  constants,
-		subs:       make([]chan SyncUpdate, 0),
 		syncPrefix: config.SyncPrefix,
-		selfsets:   make([]string, 0),
 		local:      NewStateVector(),
 		logger:     log.WithField("module", "svs"),
+		metrics:    newCoreMetrics(config.Metrics),
+		events:     eventEmitter{sink: config.EventSink, clock: constants.Clock},
 		intCfg: &ndn.InterestConfig{
 			MustBeFresh: true,
 			CanBePrefix: true,
 			Lifetime:    utl.IdPtr(constants.SyncInterestLifeTime),
 		},
-		formal: config.FormalEncoding,
+		formal:     config.FormalEncoding,
+		compressed: config.CompressedEncoding,
+		partial:    config.PartialVector,
 	}
-	c.scheduler = NewScheduler(c.sendInterest)
+	c.signer, c.validator = coreSecurity(config.Signer, config.Validator)
+	c.selfsets = restoreState(c.local, config.InitialState, config.SelfDatasets)
+	c.scheduler = NewScheduler(c.onTimer, constants.Clock)
 	c.scheduler.ApplyBounds(JitterToBounds(constants.SyncInterval, constants.SyncIntervalJitter))
 	return c
 }
 
-func (c *oneStateCore) Listen() {
+func (c *oneStateCore) Listen(ctx context.Context) error {
+	if c.isListening {
+		return ErrAlreadyListening
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	err := c.app.AttachHandler(c.syncPrefix, c.onInterest)
 	if err != nil {
-		c.logger.Errorf("Unable to register handler: %+v", err)
-		return
+		return fmt.Errorf("unable to register handler: %w", err)
 	}
-	err = c.app.RegisterRoute(c.syncPrefix)
+	err = registerRoute(ctx, c.app, c.syncPrefix)
 	if err != nil {
-		c.logger.Errorf("Unable to register route: %+v", err)
-		return
+		c.app.DetachHandler(c.syncPrefix)
+		return fmt.Errorf("unable to register route: %w", err)
 	}
 	c.isListening = true
 	c.logger.Info("Sync-side Registered and Handled.")
+	return nil
 }
 
-func (c *oneStateCore) Activate(immediateStart bool) {
+func (c *oneStateCore) Activate(ctx context.Context, immediateStart bool) error {
+	if c.isActive.Load() {
+		return ErrAlreadyActive
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	c.scheduler.Start(immediateStart)
-	c.isActive = true
+	c.isActive.Store(true)
 	c.logger.Info("Core Activated.")
+	return nil
 }
 
-func (c *oneStateCore) Shutdown() {
-	if c.isActive {
-		c.scheduler.Stop()
+func (c *oneStateCore) Shutdown(ctx context.Context) error {
+	var errs []error
+	if c.isActive.Load() {
+		// the scheduler is stopped regardless, ctx only bounds the wait
+		stopped := make(chan struct{})
+		go func() {
+			c.scheduler.Stop()
+			close(stopped)
+		}()
+		err := waitContext(ctx, stopped)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stop scheduler error: %w", err))
+		}
+		c.isActive.Store(false)
 	}
 	if c.isListening {
 		err := c.app.DetachHandler(c.syncPrefix)
 		if err != nil {
-			c.logger.Errorf("Detech handler error: %+v", err)
+			errs = append(errs, fmt.Errorf("detach handler error: %w", err))
 		}
-		err = c.app.UnregisterRoute(c.syncPrefix)
+		err = unregisterRoute(ctx, c.app, c.syncPrefix)
 		if err != nil {
-			c.logger.Errorf("Unregister route error: %+v", err)
+			errs = append(errs, fmt.Errorf("unregister route error: %w", err))
 		}
+		c.isListening = false
 	}
-	for _, sub := range c.subs {
-		close(sub)
-	}
+	c.subs.close()
 	c.logger.Info("Core Shutdown.")
+	return errors.Join(errs...)
 }
 
 func (c *oneStateCore) Update(dsname enc.Name, seqno uint64) {
@@ -95,29 +133,34 @@ func (c *oneStateCore) Update(dsname enc.Name, seqno uint64) {
 		return
 	}
 	dsstr := dsname.String()
-	if seqno <= c.local.Get(dsstr) {
+	c.local.Lock()
+	lVal := c.local.Get(dsstr)
+	if seqno <= lVal {
+		c.local.Unlock()
 		c.logger.Warn("The Core was updated with a non-new seqno.")
 		return
 	}
-	if c.local.Get(dsstr) == 0 {
+	if lVal == 0 {
 		c.selfsets = append(c.selfsets, dsstr)
-	} else {
-		if !slices.Contains(c.selfsets, dsstr) {
-			c.logger.Warn("The Core was updated with a dataset not previously updated by the node.")
-			return
-		}
+	} else if !slices.Contains(c.selfsets, dsstr) {
+		c.local.Unlock()
+		c.logger.Warn("The Core was updated with a dataset not previously updated by the node.")
+		return
 	}
-	c.local.Lock()
 	c.local.Set(dsstr, dsname, seqno, false)
-	c.local.Update(dsstr)
+	c.local.Update(dsstr, c.constants.Clock.Now())
 	c.local.Unlock()
-	c.scheduler.Skip()
+	if c.isActive.Load() {
+		c.scheduler.Skip()
+	}
+}
+
+func (c *oneStateCore) Subscribe() <-chan SyncUpdate {
+	return c.subs.subscribe()
 }
 
-func (c *oneStateCore) Subscribe() chan SyncUpdate {
-	ch := make(chan SyncUpdate, c.constants.InitialMissingChannelSize)
-	c.subs = append(c.subs, ch)
-	return ch
+func (c *oneStateCore) Unsubscribe(ch <-chan SyncUpdate) {
+	c.subs.unsubscribe(ch)
 }
 
 func (c *oneStateCore) StateVector() *StateVector {
@@ -129,29 +172,59 @@ func (c *oneStateCore) FeedInterest(interest ndn.Interest, rawInterest enc.Wire,
 }
 
 func (c *oneStateCore) onInterest(interest ndn.Interest, rawInterest enc.Wire, sigCovered enc.Wire, reply ndn.ReplyFunc, deadline time.Time) {
-	// TODO: VERIFY THE INTEREST
+	if !c.validator.Validate(interest.Name(), sigCovered, interest.Signature()) {
+		c.logger.Warn("Received unverifiable statevector.")
+		c.metrics.invalid.Add(1)
+		c.events.emit(Event{Type: InterestRejectedEvent, State: "steady", Reason: "unverifiable"})
+		return
+	}
 	remote, err := ParseStateVector(enc.NewWireReader(interest.AppParam()), c.formal)
 	if err != nil {
 		c.logger.Warnf("Received unparsable statevector: %+v", err)
+		c.metrics.unparsable.Add(1)
+		c.events.emit(Event{Type: InterestRejectedEvent, State: "steady", Reason: err.Error()})
 		return
 	}
+	c.metrics.received.Add(1)
+	if c.events.enabled() {
+		c.events.emit(Event{Type: InterestReceivedEvent, State: "steady", Remote: vectorSnapshot(remote)})
+	}
 	localNewer := c.mergeVectorToLocal(remote)
 	if !localNewer {
+		c.metrics.suppressed.Add(1)
+		c.events.emit(Event{Type: InterestSuppressedEvent, State: "steady", Reason: "received vector is up to date"})
 		c.scheduler.Reset()
 	} else {
 		c.scheduler.Skip()
 	}
 }
 
+func (c *oneStateCore) onTimer() {
+	c.events.emit(Event{Type: TimerFiredEvent, State: "steady"})
+	c.sendInterest()
+}
+
 func (c *oneStateCore) sendInterest() {
 	// make the interest
-	// TODO: SIGN THE INTEREST WITH AUTHENTICATABLE KEY
-	// WARNING: SHA SIGNER PROVIDES NOTHING (signature only includes the appParams) & IS ONLY PLACEHOLDER
 	c.local.RLock()
-	appP := c.local.Encode(c.formal)
+	sv := c.local
+	if c.partial {
+		sv = c.local.Partial(int(c.constants.PartialVectorRecent), int(c.constants.PartialVectorRotation), c.rotation)
+		c.rotation += int(c.constants.PartialVectorRotation)
+	}
+	var appP enc.Wire
+	if c.compressed {
+		appP = sv.EncodeCompressed()
+	} else {
+		appP = sv.Encode(c.formal)
+	}
+	var sent map[string]uint64
+	if c.events.enabled() {
+		sent = vectorSnapshot(sv)
+	}
 	c.local.RUnlock()
 	wire, _, finalName, err := c.app.Spec().MakeInterest(
-		c.syncPrefix, c.intCfg, appP, sec.NewSha256IntSigner(c.app.Timer()),
+		c.syncPrefix, c.intCfg, appP, c.signer,
 	)
 	if err != nil {
 		c.logger.Errorf("Unable to make Sync Interest: %+v", err)
@@ -165,6 +238,8 @@ func (c *oneStateCore) sendInterest() {
 		c.logger.Errorf("Unable to send Sync Interest: %+v", err)
 		return
 	}
+	c.metrics.sent.Add(1)
+	c.events.emit(Event{Type: InterestSentEvent, State: "steady", Local: sent})
 }
 
 func (c *oneStateCore) mergeVectorToLocal(vector *StateVector) bool {
@@ -180,20 +255,30 @@ func (c *oneStateCore) mergeVectorToLocal(vector *StateVector) bool {
 			missing = append(missing, MissingData{Dataset: p.Kname, StartSeq: lVal + 1, EndSeq: p.Val})
 			c.local.Set(p.Kstr, p.Kname, p.Val, false)
 		} else if lVal > p.Val {
-			if slices.Contains(c.selfsets, p.Kstr) && time.Since(c.local.LastUpdated(p.Kstr)) < c.constants.SuppressionInterval {
+			if slices.Contains(c.selfsets, p.Kstr) && c.constants.Clock.Now().Sub(c.local.LastUpdated(p.Kstr)) < c.constants.SuppressionInterval {
 				continue
 			}
 			lNewer = true
+			if c.partial {
+				// make sure the entry is part of the next partial vector
+				c.local.Set(p.Kstr, p.Kname, lVal, false)
+			}
 		}
 	}
-	if vector.Len() < c.local.Len() {
+	if !c.partial && vector.Len() < c.local.Len() {
 		lNewer = true
 	}
+	var merged map[string]uint64
+	if c.events.enabled() {
+		merged = vectorSnapshot(c.local)
+	}
 	c.local.Unlock()
+	if merged != nil {
+		c.events.emit(Event{Type: VectorMergedEvent, State: "steady", Local: merged, Missing: eventRanges(missing), Newer: lNewer})
+	}
 	if len(missing) != 0 {
-		for _, sub := range c.subs {
-			sub <- missing
-		}
+		c.metrics.found(missing)
+		c.subs.publish(missing)
 	}
 	return lNewer
 }
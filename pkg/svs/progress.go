@@ -0,0 +1,73 @@
+package svs
+
+import "sync"
+
+// progress tracks, per dataset, the seqno up to which every publication has been handled.
+// Only that much of the state vector is persisted, so publications still pending when the
+// Sync stops are reported missing again once it restarts.
+type progress struct {
+	mtx     sync.Mutex
+	handled map[string]uint64
+	ahead   map[string]map[uint64]bool
+}
+
+func newProgress(initial *StateVector) *progress {
+	p := &progress{
+		handled: make(map[string]uint64),
+		ahead:   make(map[string]map[uint64]bool),
+	}
+	if initial != nil {
+		for e := initial.Entries().Front(); e != nil; e = e.Next() {
+			p.handled[e.Kstr] = e.Val
+		}
+	}
+	return p
+}
+
+// Publications may be handled out of order, the handled seqno only moves past contiguous ones.
+func (p *progress) done(dataset string, seqno uint64) {
+	if p == nil {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	h := p.handled[dataset]
+	if seqno <= h {
+		return
+	}
+	ahead := p.ahead[dataset]
+	if seqno > h+1 {
+		if ahead == nil {
+			ahead = make(map[uint64]bool)
+			p.ahead[dataset] = ahead
+		}
+		ahead[seqno] = true
+		return
+	}
+	for h = seqno; ahead[h+1]; h++ {
+		delete(ahead, h+1)
+	}
+	p.handled[dataset] = h
+	if ahead != nil && len(ahead) == 0 {
+		delete(p.ahead, dataset)
+	}
+}
+
+// Returns a copy of the vector with no entry beyond what has been handled.
+// Without a progress, the vector itself is returned.
+func (p *progress) limit(sv *StateVector) *StateVector {
+	if p == nil {
+		return sv
+	}
+	sv.RLock()
+	ret := CopyStateVector(*sv)
+	sv.RUnlock()
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for e := ret.Entries().Front(); e != nil; e = e.Next() {
+		if h := p.handled[e.Kstr]; h < e.Val {
+			ret.Set(e.Kstr, e.Kname, h, true)
+		}
+	}
+	return ret
+}
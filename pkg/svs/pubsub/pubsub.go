@@ -0,0 +1,193 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+)
+
+// Separates the publisher from the topic within a dataset name.
+var TopicComponent = enc.NewStringComponent(enc.TypeKeywordNameComponent, "topic")
+
+type Publication struct {
+	Publisher enc.Name
+	Topic     enc.Name
+	Seqno     uint64
+	Data      ndn.Data
+}
+
+type PubSub interface {
+	Listen(context.Context) error
+	Activate(context.Context, bool) error
+	Shutdown(context.Context) error
+	Publish(topic enc.Name, payload []byte) (uint64, error)
+	Subscribe(topicPrefix enc.Name, callback func(Publication)) uint64
+	Unsubscribe(id uint64)
+	Sync() svs.SharedSync
+}
+
+type subscription struct {
+	prefix   enc.Name
+	callback func(Publication)
+}
+
+type pubSub struct {
+	sync     svs.SharedSync
+	source   enc.Name
+	cache    bool
+	missChan <-chan svs.SyncUpdate
+	subs     map[uint64]*subscription
+	nextID   uint64
+	mtx      sync.RWMutex
+	done     chan struct{}
+}
+
+// The DataCallback and HandlingOption of the config are replaced by the PubSub. The TrustPolicy
+// is given the publisher of a topic as the source, so keys stay named under the publisher.
+func NewPubSub(app *eng.Engine, config *svs.SharedConfig, constants *svs.Constants) (PubSub, error) {
+	p := &pubSub{
+		source: config.Source,
+		cache:  config.CacheOthers,
+		subs:   make(map[uint64]*subscription),
+		done:   make(chan struct{}),
+	}
+	cfg := *config
+	cfg.HandlingOption = svs.NoHandling
+	cfg.DataCallback = p.onData
+	if cfg.TrustPolicy != nil {
+		cfg.TrustPolicy = publisherPolicy{cfg.TrustPolicy}
+	}
+	s, err := svs.NewSharedSync(app, &cfg, constants)
+	if err != nil {
+		return nil, err
+	}
+	p.sync = s
+	p.missChan = s.Core().Subscribe()
+	go p.handleMissing()
+	return p, nil
+}
+
+func GetBasicConfig(source enc.Name, group enc.Name) *svs.SharedConfig {
+	return svs.GetBasicSharedConfig(source, group, nil)
+}
+
+func (p *pubSub) Listen(ctx context.Context) error { return p.sync.Listen(ctx) }
+
+func (p *pubSub) Activate(ctx context.Context, immediateStart bool) error {
+	return p.sync.Activate(ctx, immediateStart)
+}
+
+func (p *pubSub) Shutdown(ctx context.Context) error {
+	err := p.sync.Shutdown(ctx)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *pubSub) Publish(topic enc.Name, payload []byte) (uint64, error) {
+	_, seqno, err := p.sync.PublishDataTo(TopicDataset(p.source, topic), payload)
+	return seqno, err
+}
+
+// Publications under the topic prefix are fetched from now on and passed to the callback.
+func (p *pubSub) Subscribe(topicPrefix enc.Name, callback func(Publication)) uint64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.nextID++
+	p.subs[p.nextID] = &subscription{prefix: topicPrefix, callback: callback}
+	return p.nextID
+}
+
+func (p *pubSub) Unsubscribe(id uint64) {
+	p.mtx.Lock()
+	delete(p.subs, id)
+	p.mtx.Unlock()
+}
+
+func (p *pubSub) Sync() svs.SharedSync {
+	return p.sync
+}
+
+func (p *pubSub) subscribed(topic enc.Name) bool {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+	for _, sub := range p.subs {
+		if sub.prefix.IsPrefix(topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pubSub) handleMissing() {
+	defer close(p.done)
+	for missing := range p.missChan {
+		for _, m := range missing {
+			_, topic, ok := SplitDataset(m.Dataset)
+			if !ok || !p.subscribed(topic) {
+				continue
+			}
+			for m.StartSeq <= m.EndSeq {
+				p.sync.NeedData(m.Dataset, m.StartSeq, p.cache)
+				m.StartSeq++
+			}
+		}
+	}
+}
+
+func (p *pubSub) onData(dataset enc.Name, seqno uint64, data ndn.Data) {
+	publisher, topic, ok := SplitDataset(dataset)
+	if !ok {
+		return
+	}
+	var calls []func(Publication)
+	p.mtx.RLock()
+	for _, sub := range p.subs {
+		if sub.prefix.IsPrefix(topic) {
+			calls = append(calls, sub.callback)
+		}
+	}
+	p.mtx.RUnlock()
+	pub := Publication{Publisher: publisher, Topic: topic, Seqno: seqno, Data: data}
+	for _, call := range calls {
+		call(pub)
+	}
+}
+
+type publisherPolicy struct {
+	svs.TrustPolicy
+}
+
+func (p publisherPolicy) Permits(dataset enc.Name, dataName enc.Name, keyName enc.Name) bool {
+	if publisher, _, ok := SplitDataset(dataset); ok {
+		dataset = publisher
+	}
+	return p.TrustPolicy.Permits(dataset, dataName, keyName)
+}
+
+// Returns <publisher>/<TopicComponent>/<topic>.
+func TopicDataset(publisher enc.Name, topic enc.Name) enc.Name {
+	ret := make(enc.Name, 0, len(publisher)+len(topic)+1)
+	ret = append(ret, publisher...)
+	ret = append(ret, TopicComponent)
+	return append(ret, topic...)
+}
+
+func SplitDataset(dataset enc.Name) (publisher enc.Name, topic enc.Name, ok bool) {
+	for i, c := range dataset {
+		if c.Equal(TopicComponent) {
+			return dataset[:i], dataset[i+1:], true
+		}
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,218 @@
+package svs
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+)
+
+type RetentionPolicy struct {
+	MaxPacketsPerSource int           // 0 = inf
+	MaxAge              time.Duration // 0 = inf
+	MaxBytes            int           // 0 = inf, across all sources
+	CompactInterval     time.Duration
+}
+
+func GetDefaultRetentionPolicy() *RetentionPolicy {
+	return &RetentionPolicy{
+		MaxPacketsPerSource: 1000,
+		MaxAge:              24 * time.Hour,
+		MaxBytes:            64 * 1024 * 1024,
+		CompactInterval:     60000 * time.Millisecond,
+	}
+}
+
+// A publication is retained or evicted with all of its segments and its mapping.
+type retainedPublication struct {
+	name  enc.Name
+	key   string
+	group string
+	parts map[string]int // stored key to size
+	size  int
+	added time.Time
+	inGrp *list.Element
+	inAll *list.Element
+}
+
+type compactor struct {
+	storage  Database
+	policy   *RetentionPolicy
+	clock    Clock
+	onEvict  func(enc.Name)
+	pubs     map[string]*retainedPublication
+	groups   map[string]*list.List
+	counts   map[string]int // packets per group
+	all      *list.List
+	bytes    int
+	mtx      sync.Mutex
+	quit     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newCompactor(storage Database, policy *RetentionPolicy, clock Clock, onEvict func(enc.Name)) *compactor {
+	c := &compactor{
+		storage: storage,
+		policy:  policy,
+		clock:   clock,
+		onEvict: onEvict,
+		pubs:    make(map[string]*retainedPublication),
+		groups:  make(map[string]*list.List),
+		counts:  make(map[string]int),
+		all:     list.New(),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	c.rebuild()
+	go c.run()
+	return c
+}
+
+// Packets stored before a restart are treated as if they were added now.
+func (c *compactor) rebuild() {
+	type found struct {
+		name enc.Name
+		size int
+	}
+	var packets []found
+	c.storage.ForEach(func(key []byte, val []byte) bool {
+		name, err := enc.NameFromBytes(key)
+		if err == nil && isPacketName(name) {
+			packets = append(packets, found{name, len(val)})
+		}
+		return true
+	})
+	for _, p := range packets {
+		c.track(p.name, p.size)
+	}
+}
+
+func (c *compactor) track(name enc.Name, size int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	pubName := publicationName(name)
+	key := string(pubName.Bytes())
+	part := string(name.Bytes())
+	p, ok := c.pubs[key]
+	if !ok {
+		p = &retainedPublication{
+			name:  pubName,
+			key:   key,
+			group: retentionGroup(name),
+			parts: make(map[string]int),
+			added: c.clock.Now(),
+		}
+		grp, ok := c.groups[p.group]
+		if !ok {
+			grp = list.New()
+			c.groups[p.group] = grp
+		}
+		p.inGrp = grp.PushBack(p)
+		p.inAll = c.all.PushBack(p)
+		c.pubs[key] = p
+	}
+	if old, ok := p.parts[part]; ok {
+		p.size -= old
+		c.bytes -= old
+	} else {
+		c.counts[p.group]++
+	}
+	p.parts[part] = size
+	p.size += size
+	c.bytes += size
+}
+
+func (c *compactor) compact() {
+	var evicted []*retainedPublication
+	c.mtx.Lock()
+	if c.policy.MaxPacketsPerSource > 0 {
+		for group, grp := range c.groups {
+			for c.counts[group] > c.policy.MaxPacketsPerSource {
+				evicted = append(evicted, c.untrack(grp.Front().Value.(*retainedPublication)))
+			}
+		}
+	}
+	if c.policy.MaxAge > 0 {
+		now := c.clock.Now()
+		for e := c.all.Front(); e != nil && now.Sub(e.Value.(*retainedPublication).added) > c.policy.MaxAge; e = c.all.Front() {
+			evicted = append(evicted, c.untrack(e.Value.(*retainedPublication)))
+		}
+	}
+	if c.policy.MaxBytes > 0 {
+		for c.bytes > c.policy.MaxBytes && c.all.Len() > 0 {
+			evicted = append(evicted, c.untrack(c.all.Front().Value.(*retainedPublication)))
+		}
+	}
+	c.mtx.Unlock()
+	for _, p := range evicted {
+		for part := range p.parts {
+			c.storage.Remove([]byte(part))
+		}
+		c.storage.Remove(mappingKey(p.name))
+		if c.onEvict != nil {
+			c.onEvict(p.name)
+		}
+	}
+}
+
+func (c *compactor) untrack(p *retainedPublication) *retainedPublication {
+	grp := c.groups[p.group]
+	grp.Remove(p.inGrp)
+	c.counts[p.group] -= len(p.parts)
+	if grp.Len() == 0 {
+		delete(c.groups, p.group)
+		delete(c.counts, p.group)
+	}
+	c.all.Remove(p.inAll)
+	delete(c.pubs, p.key)
+	c.bytes -= p.size
+	return p
+}
+
+func (c *compactor) run() {
+	defer close(c.done)
+	interval := c.policy.CompactInterval
+	if interval <= 0 {
+		interval = GetDefaultRetentionPolicy().CompactInterval
+	}
+	timer := c.clock.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C():
+			c.compact()
+			timer.Reset(interval)
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// Safe to call more than once.
+func (c *compactor) stop() {
+	c.stopOnce.Do(func() { close(c.quit) })
+	<-c.done
+}
+
+func isPacketName(name enc.Name) bool {
+	name = publicationName(name)
+	return len(name) > 0 && name[len(name)-1].Typ == enc.TypeSequenceNumNameComponent
+}
+
+func publicationName(name enc.Name) enc.Name {
+	if len(name) > 0 && name[len(name)-1].Typ == enc.TypeSegmentNameComponent {
+		return name[:len(name)-1]
+	}
+	return name
+}
+
+// Every publication of a source shares the name before its seqno.
+func retentionGroup(name enc.Name) string {
+	name = publicationName(name)
+	if len(name) > 0 {
+		name = name[:len(name)-1]
+	}
+	return name.String()
+}
@@ -1,7 +1,6 @@
 package svs
 
 import (
-	"math/rand/v2"
 	"sync"
 	"time"
 )
@@ -31,7 +30,8 @@ type Scheduler interface {
 type scheduler struct {
 	function    func()
 	actions     chan action
-	timer       *time.Timer
+	clock       Clock
+	timer       Timer
 	minInterval time.Duration
 	maxInterval time.Duration
 	cycleLength time.Duration
@@ -40,17 +40,20 @@ type scheduler struct {
 	done        chan struct{}
 }
 
-func NewScheduler(function func()) Scheduler {
+func NewScheduler(function func(), clock Clock) Scheduler {
 	return &scheduler{
 		function: function,
 		actions:  make(chan action, 3),
+		clock:    clock,
 	}
 }
 
-// Must be called before Start()
+// Takes effect from the next cycle, also while running.
 func (s *scheduler) ApplyBounds(min, max time.Duration) {
+	s.mtx.Lock()
 	s.minInterval = min
 	s.maxInterval = max
+	s.mtx.Unlock()
 }
 
 func (s *scheduler) Start(execute bool) {
@@ -70,7 +73,7 @@ func (s *scheduler) Set(v time.Duration) { s.actions <- action{typ: actionSet, v
 func (s *scheduler) TimeLeft() time.Duration {
 	s.mtx.RLock()
 	defer s.mtx.RUnlock()
-	return time.Duration(s.cycleLength) - time.Since(s.startTime)
+	return s.cycleLength - s.clock.Now().Sub(s.startTime)
 }
 
 func (s *scheduler) target(execute bool) {
@@ -81,15 +84,15 @@ func (s *scheduler) target(execute bool) {
 	s.newTimer()
 	for {
 		select {
-		case <-s.timer.C:
+		case <-s.timer.C():
 			s.function()
-			s.resetTimer(BoundedRand(s.minInterval, s.maxInterval))
+			s.resetTimer(s.nextInterval())
 		case a := <-s.actions:
 			switch a.typ {
 			case actionStop:
 				if !s.timer.Stop() {
 					select {
-					case <-s.timer.C:
+					case <-s.timer.C():
 					default:
 					}
 				}
@@ -98,7 +101,7 @@ func (s *scheduler) target(execute bool) {
 				s.function()
 				fallthrough
 			case actionReset:
-				s.resetTimer(BoundedRand(s.minInterval, s.maxInterval))
+				s.resetTimer(s.nextInterval())
 			case actionSet:
 				s.resetTimer(a.val)
 			default:
@@ -108,22 +111,28 @@ func (s *scheduler) target(execute bool) {
 }
 
 func (s *scheduler) newTimer() {
-	r := BoundedRand(s.minInterval, s.maxInterval)
+	r := s.nextInterval()
 	s.mtx.Lock()
-	s.startTime = time.Now()
+	s.startTime = s.clock.Now()
 	s.cycleLength = r
 	s.mtx.Unlock()
-	s.timer = time.NewTimer(r)
+	s.timer = s.clock.NewTimer(r)
+}
+
+func (s *scheduler) nextInterval() time.Duration {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return boundedRand(s.clock, s.minInterval, s.maxInterval)
 }
 
 func (s *scheduler) resetTimer(val time.Duration) {
 	s.mtx.Lock()
-	s.startTime = time.Now()
+	s.startTime = s.clock.Now()
 	s.cycleLength = val
 	s.mtx.Unlock()
 	if !s.timer.Stop() {
 		select {
-		case <-s.timer.C:
+		case <-s.timer.C():
 		default:
 		}
 	}
@@ -131,7 +140,7 @@ func (s *scheduler) resetTimer(val time.Duration) {
 }
 
 func BoundedRand(min, max time.Duration) time.Duration {
-	return min + rand.N(max-min+1)
+	return boundedRand(systemClock{}, min, max)
 }
 
 func JitterToBounds(base time.Duration, jitter float64) (time.Duration, time.Duration) {
@@ -0,0 +1,284 @@
+package svs
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	sec "github.com/zjkmxy/go-ndn/pkg/security"
+	utl "github.com/zjkmxy/go-ndn/pkg/utils"
+)
+
+var ErrUnsupportedKey = errors.New("svs: unsupported key type")
+
+type Validator interface {
+	Validate(enc.Name, enc.Wire, ndn.Signature) bool
+}
+
+type sha256Validator struct{}
+
+// Only checks integrity, anyone can produce a valid digest.
+func NewSha256Validator() Validator { return sha256Validator{} }
+
+func (sha256Validator) Validate(name enc.Name, sigCovered enc.Wire, sig ndn.Signature) bool {
+	return sig != nil && sec.Sha256Validate(sigCovered, sig)
+}
+
+type hmacValidator struct {
+	key []byte
+}
+
+func NewHmacValidator(key []byte) Validator { return &hmacValidator{key: key} }
+
+func (v *hmacValidator) Validate(name enc.Name, sigCovered enc.Wire, sig ndn.Signature) bool {
+	return sig != nil && sec.HmacValidate(sigCovered, sig, v.key)
+}
+
+type ecdsaValidator struct {
+	key *ecdsa.PublicKey
+}
+
+func NewEcdsaValidator(key *ecdsa.PublicKey) Validator { return &ecdsaValidator{key: key} }
+
+func (v *ecdsaValidator) Validate(name enc.Name, sigCovered enc.Wire, sig ndn.Signature) bool {
+	return sig != nil && sec.EcdsaValidate(sigCovered, sig, v.key)
+}
+
+type eddsaValidator struct {
+	key ed25519.PublicKey
+}
+
+func NewEddsaValidator(key ed25519.PublicKey) Validator { return &eddsaValidator{key: key} }
+
+func (v *eddsaValidator) Validate(name enc.Name, sigCovered enc.Wire, sig ndn.Signature) bool {
+	return sig != nil && sec.EddsaValidate(sigCovered, sig, v.key)
+}
+
+// KeyValidator picks the key to validate with through the signature's KeyLocator.
+type KeyValidator struct {
+	keys map[string]Validator
+	mtx  sync.RWMutex
+}
+
+func NewKeyValidator() *KeyValidator {
+	return &KeyValidator{keys: make(map[string]Validator)}
+}
+
+// Accepts []byte (hmac), *ecdsa.PublicKey, and ed25519.PublicKey.
+func (v *KeyValidator) AddKey(keyName enc.Name, key any) error {
+	var val Validator
+	switch k := key.(type) {
+	case []byte:
+		val = NewHmacValidator(k)
+	case *ecdsa.PublicKey:
+		val = NewEcdsaValidator(k)
+	case ed25519.PublicKey:
+		val = NewEddsaValidator(k)
+	default:
+		return ErrUnsupportedKey
+	}
+	v.mtx.Lock()
+	v.keys[keyName.String()] = val
+	v.mtx.Unlock()
+	return nil
+}
+
+func (v *KeyValidator) RemoveKey(keyName enc.Name) {
+	v.mtx.Lock()
+	delete(v.keys, keyName.String())
+	v.mtx.Unlock()
+}
+
+func (v *KeyValidator) Validate(name enc.Name, sigCovered enc.Wire, sig ndn.Signature) bool {
+	if sig == nil || sig.KeyName() == nil {
+		return false
+	}
+	v.mtx.RLock()
+	val, ok := v.keys[sig.KeyName().String()]
+	v.mtx.RUnlock()
+	return ok && val.Validate(name, sigCovered, sig)
+}
+
+type replayState struct {
+	time time.Time
+	seq  uint64
+}
+
+type replayValidator struct {
+	validator Validator
+	grace     time.Duration
+	clock     Clock
+	last      map[string]replayState
+	mtx       sync.Mutex
+}
+
+// A signature alone keeps a captured Sync Interest valid forever. On top of the given Validator,
+// this refuses an Interest whose SigTime is more than grace away from now, or whose SigTime and
+// SeqNum are not past the last ones accepted from its key. Interest signers (forInt) set both.
+func NewReplayValidator(validator Validator, grace time.Duration, clock Clock) Validator {
+	return &replayValidator{
+		validator: validator,
+		grace:     grace,
+		clock:     clock,
+		last:      make(map[string]replayState),
+	}
+}
+
+func (v *replayValidator) Validate(name enc.Name, sigCovered enc.Wire, sig ndn.Signature) bool {
+	if sig == nil || sig.SigTime() == nil || sig.SigSeqNum() == nil {
+		return false
+	}
+	if !v.validator.Validate(name, sigCovered, sig) {
+		return false
+	}
+	cur := replayState{time: *sig.SigTime(), seq: *sig.SigSeqNum()}
+	if diff := v.clock.Now().Sub(cur.time); diff > v.grace || diff < -v.grace {
+		return false
+	}
+	key := sig.KeyName().String()
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	// the time moves on across restarts of the signer, which start over from seqno 1
+	if last, ok := v.last[key]; ok && !cur.time.After(last.time) && (!cur.time.Equal(last.time) || cur.seq <= last.seq) {
+		return false
+	}
+	v.last[key] = cur
+	return true
+}
+
+// Interest signers (forInt) attach a nonce, time, and seqno to every signature.
+type intSigInfo struct {
+	timer ndn.Timer
+	seq   uint64
+	mtx   sync.Mutex
+}
+
+func (i *intSigInfo) apply(cfg *ndn.SigConfig) {
+	i.mtx.Lock()
+	i.seq++
+	cfg.SeqNum = utl.IdPtr(i.seq)
+	i.mtx.Unlock()
+	cfg.Nonce = i.timer.Nonce()
+	cfg.SigTime = utl.IdPtr(i.timer.Now())
+}
+
+type hmacSigner struct {
+	keyName enc.Name
+	key     []byte
+	intInfo *intSigInfo
+}
+
+// Unlike go-ndn's HMAC Interest signer, the key itself is never placed in the KeyLocator.
+func NewHmacSigner(keyName enc.Name, key []byte, forInt bool) ndn.Signer {
+	s := &hmacSigner{keyName: keyName, key: key}
+	if forInt {
+		s.intInfo = &intSigInfo{timer: eng.NewTimer()}
+	}
+	return s
+}
+
+func (s *hmacSigner) SigInfo() (*ndn.SigConfig, error) {
+	cfg := &ndn.SigConfig{Type: ndn.SignatureHmacWithSha256, KeyName: s.keyName}
+	if s.intInfo != nil {
+		s.intInfo.apply(cfg)
+	}
+	return cfg, nil
+}
+
+func (s *hmacSigner) EstimateSize() uint { return sha256.Size }
+
+func (s *hmacSigner) ComputeSigValue(covered enc.Wire) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.key)
+	for _, buf := range covered {
+		if _, err := mac.Write(buf); err != nil {
+			return nil, enc.ErrUnexpected{Err: err}
+		}
+	}
+	return mac.Sum(nil), nil
+}
+
+type eddsaSigner struct {
+	keyName enc.Name
+	key     ed25519.PrivateKey
+	intInfo *intSigInfo
+}
+
+func NewEddsaSigner(keyName enc.Name, key ed25519.PrivateKey, forInt bool) ndn.Signer {
+	s := &eddsaSigner{keyName: keyName, key: key}
+	if forInt {
+		s.intInfo = &intSigInfo{timer: eng.NewTimer()}
+	}
+	return s
+}
+
+func (s *eddsaSigner) SigInfo() (*ndn.SigConfig, error) {
+	cfg := &ndn.SigConfig{Type: ndn.SignatureEd25519, KeyName: s.keyName}
+	if s.intInfo != nil {
+		s.intInfo.apply(cfg)
+	}
+	return cfg, nil
+}
+
+func (s *eddsaSigner) EstimateSize() uint { return ed25519.SignatureSize }
+
+func (s *eddsaSigner) ComputeSigValue(covered enc.Wire) ([]byte, error) {
+	return ed25519.Sign(s.key, covered.Join()), nil
+}
+
+func NewEcdsaSigner(keyName enc.Name, key *ecdsa.PrivateKey, forInt bool) ndn.Signer {
+	return sec.NewEccSigner(false, forInt, 0, key, keyName)
+}
+
+// TrustPolicy decides which keys may sign a publication of a source.
+type TrustPolicy interface {
+	Permits(source enc.Name, dataName enc.Name, keyName enc.Name) bool
+}
+
+type TrustPolicyFunc func(source enc.Name, dataName enc.Name, keyName enc.Name) bool
+
+func (f TrustPolicyFunc) Permits(source enc.Name, dataName enc.Name, keyName enc.Name) bool {
+	return f(source, dataName, keyName)
+}
+
+// Publications of <source> must be signed by a key named under <source>/KEY.
+func NewSourceKeyPolicy() TrustPolicy {
+	keyComp := enc.NewStringComponent(enc.TypeGenericNameComponent, "KEY")
+	return TrustPolicyFunc(func(source enc.Name, dataName enc.Name, keyName enc.Name) bool {
+		prefix := make(enc.Name, 0, len(source)+1)
+		prefix = append(append(prefix, source...), keyComp)
+		return keyName != nil && prefix.IsPrefix(keyName)
+	})
+}
+
+type dataChecker struct {
+	policy    TrustPolicy
+	validator Validator
+}
+
+func newDataChecker(policy TrustPolicy, validator Validator) *dataChecker {
+	if validator == nil {
+		validator = NewSha256Validator()
+	}
+	return &dataChecker{policy: policy, validator: validator}
+}
+
+func (c *dataChecker) check(source enc.Name, data ndn.Data, sigCovered enc.Wire) bool {
+	if data == nil {
+		return false
+	}
+	sig := data.Signature()
+	if sig == nil {
+		return false
+	}
+	if c.policy != nil && !c.policy.Permits(source, data.Name(), sig.KeyName()) {
+		return false
+	}
+	return c.validator.Validate(data.Name(), sigCovered, sig)
+}
@@ -0,0 +1,144 @@
+package svs
+
+import (
+	"errors"
+	"time"
+
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+)
+
+func splitSegments(content []byte, size int) [][]byte {
+	if size <= 0 || len(content) <= size {
+		return [][]byte{content}
+	}
+	ret := make([][]byte, 0, (len(content)+size-1)/size)
+	for len(content) > size {
+		ret = append(ret, content[:size])
+		content = content[size:]
+	}
+	return append(ret, content)
+}
+
+func segmentName(name enc.Name, seg uint64) enc.Name {
+	ret := make(enc.Name, len(name), len(name)+1)
+	copy(ret, name)
+	return append(ret, enc.NewSegmentComponent(seg))
+}
+
+var (
+	errForeignSegment     = errors.New("svs: segment does not belong to the publication")
+	errFinalBlockMismatch = errors.New("svs: segment disagrees on the FinalBlockId")
+)
+
+// segmentFetch follows a publication through its segments, if there are any.
+type segmentFetch struct {
+	first ndn.Data
+	parts []enc.Wire
+	next  uint64
+}
+
+func (f *segmentFetch) interestName(base enc.Name) enc.Name {
+	if f.parts == nil {
+		return base
+	}
+	return segmentName(base, f.next)
+}
+
+// Returns done once the whole publication has arrived. The FinalBlockId comes from the
+// publisher, so a publication of more than max segments is refused before allocating.
+func (f *segmentFetch) add(data ndn.Data, max uint) (done bool, err error) {
+	name := data.Name()
+	if len(name) == 0 || name[len(name)-1].Typ != enc.TypeSegmentNameComponent {
+		if f.parts != nil {
+			return true, errForeignSegment
+		}
+		f.first = data
+		return true, nil
+	}
+	fbi := data.FinalBlockID()
+	if f.parts == nil {
+		// a prefix Interest may be answered by any segment, not only the first
+		if fbi == nil || fbi.Typ != enc.TypeSegmentNameComponent {
+			f.first = data
+			return true, nil
+		}
+		if fbi.NumberVal() >= uint64(max) {
+			return true, ErrTooManySegments
+		}
+		f.parts = make([]enc.Wire, fbi.NumberVal()+1)
+	} else if fbi != nil && (fbi.Typ != enc.TypeSegmentNameComponent || fbi.NumberVal() != uint64(len(f.parts)-1)) {
+		return true, errFinalBlockMismatch
+	}
+	seg := name[len(name)-1].NumberVal()
+	if seg >= uint64(len(f.parts)) {
+		return true, errForeignSegment
+	}
+	if f.first == nil || seg == 0 {
+		f.first = data
+	}
+	f.parts[seg] = data.Content()
+	if f.parts[seg] == nil {
+		f.parts[seg] = enc.Wire{}
+	}
+	for f.next < uint64(len(f.parts)) && f.parts[f.next] != nil {
+		f.next++
+	}
+	return f.next >= uint64(len(f.parts)), nil
+}
+
+func (f *segmentFetch) data(base enc.Name) ndn.Data {
+	if f.parts == nil {
+		return f.first
+	}
+	content := make(enc.Wire, 0, len(f.parts))
+	for _, p := range f.parts {
+		content = append(content, p...)
+	}
+	return &segmentedData{name: base, first: f.first, content: content}
+}
+
+// segmentedData is a reassembled publication. Every segment was validated on arrival,
+// the Signature of the first segment is exposed.
+type segmentedData struct {
+	name    enc.Name
+	first   ndn.Data
+	content enc.Wire
+}
+
+func (d *segmentedData) Name() enc.Name                { return d.name }
+func (d *segmentedData) ContentType() *ndn.ContentType { return d.first.ContentType() }
+func (d *segmentedData) Freshness() *time.Duration     { return d.first.Freshness() }
+func (d *segmentedData) FinalBlockID() *enc.Component  { return d.first.FinalBlockID() }
+func (d *segmentedData) Content() enc.Wire             { return d.content }
+func (d *segmentedData) Signature() ndn.Signature      { return d.first.Signature() }
+
+// Returns the name and wire of every packet making up the publication.
+func makePublication(spec ndn.Spec, name enc.Name, cfg *ndn.DataConfig, signer ndn.Signer, content []byte, size int, max uint) ([]enc.Name, [][]byte, error) {
+	parts := splitSegments(content, size)
+	if uint(len(parts)) > max {
+		return nil, nil, ErrTooManySegments
+	}
+	names := make([]enc.Name, len(parts))
+	wires := make([][]byte, len(parts))
+	if len(parts) == 1 {
+		wire, _, err := spec.MakeData(name, cfg, enc.Wire{content}, signer)
+		if err != nil {
+			return nil, nil, err
+		}
+		names[0], wires[0] = name, wire.Join()
+		return names, wires, nil
+	}
+	segCfg := *cfg
+	final := enc.NewSegmentComponent(uint64(len(parts) - 1))
+	segCfg.FinalBlockID = &final
+	for i, part := range parts {
+		names[i] = segmentName(name, uint64(i))
+		wire, _, err := spec.MakeData(names[i], &segCfg, enc.Wire{part}, signer)
+		if err != nil {
+			return nil, nil, err
+		}
+		wires[i] = wire.Join()
+	}
+	return names, wires, nil
+}
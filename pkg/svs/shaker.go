@@ -0,0 +1,54 @@
+package svs
+
+import (
+	"sync"
+	"time"
+)
+
+// shaker tells when inconsistent vectors come in a burst, e.g. after a partition heals,
+// and when shaking has calmed down again.
+type shaker struct {
+	mtx   sync.Mutex
+	cs    *Constants
+	seen  []time.Time
+	quiet uint
+}
+
+func newShaker(cs *Constants) *shaker {
+	return &shaker{cs: cs}
+}
+
+// Reports whether the inconsistency completes a burst of ShakingThreshold within ShakingWindow.
+func (s *shaker) inconsistent() bool {
+	if s.cs.ShakingThreshold == 0 {
+		return false
+	}
+	now := s.cs.Clock.Now()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.quiet = 0
+	kept := s.seen[:0]
+	for _, t := range s.seen {
+		if now.Sub(t) < s.cs.ShakingWindow {
+			kept = append(kept, t)
+		}
+	}
+	s.seen = append(kept, now)
+	if uint(len(s.seen)) < s.cs.ShakingThreshold {
+		return false
+	}
+	s.seen = s.seen[:0]
+	return true
+}
+
+// Called for every Sync Interest sent while shaking, reports whether shaking is over.
+func (s *shaker) round() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.quiet++
+	if s.quiet < s.cs.ShakingRounds {
+		return false
+	}
+	s.quiet = 0
+	return true
+}
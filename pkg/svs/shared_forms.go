@@ -1,6 +1,7 @@
 package svs
 
 import (
+	"context"
 	"time"
 
 	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
@@ -9,28 +10,50 @@ import (
 )
 
 type SharedSync interface {
-	Listen()
-	Activate(bool)
-	Shutdown()
+	Listen(context.Context) error
+	Activate(context.Context, bool) error
+	Shutdown(context.Context) error
 	NeedData(enc.Name, uint64, bool)
-	PublishData([]byte)
+	PublishData([]byte) (uint64, error)
+	PublishDataWithName([]byte) (enc.Name, uint64, error)
+	PublishDataWithMapping([]byte, enc.Name) (uint64, error)
+	FetchMapping(enc.Name, uint64, uint64, func(map[uint64]enc.Name, error))
+	PublishDataTo(enc.Name, []byte) (enc.Name, uint64, error)
 	FeedInterest(ndn.Interest, enc.Wire, enc.Wire, ndn.ReplyFunc, time.Time)
 	Core() Core
 }
 
+// DataCallback, InvalidCallback, and ErrorCallback are called one at a time and off the
+// engine's routine, so they may block or call back into the Sync.
 type SharedConfig struct {
 	Source               enc.Name
 	GroupPrefix          enc.Name
 	HandlingOption       HandlingOption
 	StoragePath          string
+	Storage              Database         // nil = BoltDB at StoragePath
+	Retention            *RetentionPolicy // nil = keep every packet
+	EvictCallback        func(name enc.Name)
 	DataCallback         func(enc.Name, uint64, ndn.Data)
 	FormalEncoding       bool
+	CompressedEncoding   bool // overrides FormalEncoding when sending
 	EfficientSuppression bool
+	PartialVector        bool        // every member of the group must agree
+	SyncSigner           ndn.Signer  // nil = sha256 digest
+	SyncValidator        Validator   // nil = sha256 digest
+	DataSigner           ndn.Signer  // nil = sha256 digest
+	DataValidator        Validator   // nil = sha256 digest
+	TrustPolicy          TrustPolicy // nil = any key
+	InvalidCallback      func(source enc.Name, seqno uint64, data ndn.Data)
+	ErrorCallback        func(source enc.Name, seqno uint64, err FetchError)
+	FetchPolicy          FetchPolicy                                                // nil = FIFO bounded by MaxConcurrentDataInterests
+	MappingFilter        func(source enc.Name, seqno uint64, mapping enc.Name) bool // nil = fetch everything
+	Metrics              Metrics                                                    // nil = none
+	EventSink            EventSink                                                  // nil = none, receives the events of the Core
 	// high-level only
 	CacheOthers bool
 }
 
-func NewSharedSync(app *eng.Engine, config *SharedConfig, constants *Constants) SharedSync {
+func NewSharedSync(app *eng.Engine, config *SharedConfig, constants *Constants) (SharedSync, error) {
 	return newSharedSync(app, config, constants)
 }
 
@@ -1,7 +1,10 @@
 package svs
 
 import (
-	"sync/atomic"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	log "github.com/apex/log"
@@ -16,6 +19,8 @@ type sharedFetchItem struct {
 	source  enc.Name
 	seqno   uint64
 	retries uint
+	segs    segmentFetch
+	started time.Time
 	cache   bool
 }
 
@@ -25,50 +30,83 @@ type sharedHandlerData struct {
 }
 
 type sharedSync struct {
-	app         *eng.Engine
-	core        Core
-	constants   *Constants
-	missChan    chan SyncUpdate
-	groupPrefix enc.Name
-	srcName     enc.Name
-	srcSeq      uint64
-	storage     Database
-	intCfg      *ndn.InterestConfig
-	datCfg      *ndn.DataConfig
-	logger      *log.Entry
-	dataCall    func(source enc.Name, seqno uint64, data ndn.Data)
-	fetchQueue  chan *sharedFetchItem
-	handleData  *sharedHandlerData
-	numFetches  *int32
-	isListening bool
+	app           *eng.Engine
+	core          Core
+	constants     *Constants
+	missChan      <-chan SyncUpdate
+	groupPrefix   enc.Name
+	srcName       enc.Name
+	srcSeq        uint64
+	storage       Database
+	ownStorage    bool
+	compactor     *compactor
+	progress      *progress
+	intCfg        *ndn.InterestConfig
+	datCfg        *ndn.DataConfig
+	signer        ndn.Signer
+	checker       *dataChecker
+	logger        *log.Entry
+	dataCall      func(source enc.Name, seqno uint64, data ndn.Data)
+	invalidCall   func(enc.Name, uint64, ndn.Data)
+	errorCall     func(enc.Name, uint64, FetchError)
+	calls         callQueue
+	mappingFilter func(enc.Name, uint64, enc.Name) bool
+	fetchPolicy   FetchPolicy
+	fetchMtx      sync.Mutex
+	handleData    *sharedHandlerData
+	numFetches    int
+	metrics       *syncMetrics
+	isListening   bool
 }
 
-func newSharedSync(app *eng.Engine, config *SharedConfig, constants *Constants) *sharedSync {
+func newSharedSync(app *eng.Engine, config *SharedConfig, constants *Constants) (*sharedSync, error) {
 	var s *sharedSync
 	logger := log.WithField("module", "svs")
 	syncPrefix := append(config.GroupPrefix, constants.SyncComponent)
 
 	if config.DataCallback == nil {
-		logger.Error("Fetcher based on NativeConfig needs DataCallback.")
-		return nil
+		return nil, ErrMissingCallback
+	}
+	storage, ownStorage := config.Storage, false
+	if storage == nil {
+		bolt, err := NewBoltDB(config.StoragePath, []byte("svs-packets"))
+		if err != nil {
+			return nil, fmt.Errorf("unable to create storage: %w", err)
+		}
+		storage, ownStorage = bolt, true
+	}
+	initial, srcSeq, err := loadState(storage)
+	if err != nil {
+		if ownStorage {
+			storage.Close()
+		}
+		return nil, fmt.Errorf("unable to restore state: %w", err)
+	}
+	if initial != nil && initial.Get(config.Source.String()) > srcSeq {
+		srcSeq = initial.Get(config.Source.String())
 	}
 	coreConfig := &TwoStateCoreConfig{
 		SyncPrefix:           syncPrefix,
 		FormalEncoding:       config.FormalEncoding,
+		CompressedEncoding:   config.CompressedEncoding,
 		EfficientSuppression: config.EfficientSuppression,
+		PartialVector:        config.PartialVector,
+		Signer:               config.SyncSigner,
+		Validator:            config.SyncValidator,
+		InitialState:         initial,
+		Metrics:              config.Metrics,
+		EventSink:            config.EventSink,
 	}
-	storage, err := NewBoltDB(config.StoragePath, []byte("svs-packets"))
-	if err != nil {
-		logger.Errorf("Unable to create storage: %+v", err)
-		return nil
-	}
+	coreConfig.SelfDatasets = sharedSelfDatasets(initial, config.Source, srcSeq)
 	s = &sharedSync{
 		app:         app,
 		core:        NewCore(app, coreConfig, constants),
 		constants:   constants,
 		groupPrefix: config.GroupPrefix,
 		srcName:     config.Source,
+		srcSeq:      srcSeq,
 		storage:     storage,
+		ownStorage:  ownStorage,
 		intCfg: &ndn.InterestConfig{
 			MustBeFresh: true,
 			CanBePrefix: true,
@@ -78,19 +116,34 @@ func newSharedSync(app *eng.Engine, config *SharedConfig, constants *Constants)
 			ContentType: utl.IdPtr(ndn.ContentTypeBlob),
 			Freshness:   utl.IdPtr(constants.DataPacketFreshness),
 		},
-		logger:     logger,
-		dataCall:   config.DataCallback,
-		fetchQueue: make(chan *sharedFetchItem, constants.InitialFetchQueueSize),
-		numFetches: new(int32),
+		signer:        config.DataSigner,
+		checker:       newDataChecker(config.TrustPolicy, config.DataValidator),
+		logger:        logger,
+		metrics:       newSyncMetrics(config.Metrics),
+		dataCall:      config.DataCallback,
+		invalidCall:   config.InvalidCallback,
+		errorCall:     config.ErrorCallback,
+		mappingFilter: config.MappingFilter,
+		fetchPolicy:   config.FetchPolicy,
+	}
+	if s.signer == nil {
+		s.signer = sec.NewSha256Signer()
+	}
+	if s.fetchPolicy == nil {
+		s.fetchPolicy = newFifoFetchPolicy(int(constants.MaxConcurrentDataInterests), constants.InitialFetchQueueSize)
+	}
+	if config.Retention != nil {
+		s.compactor = newCompactor(storage, config.Retention, constants.Clock, config.EvictCallback)
 	}
-	s.missChan = s.core.Subscribe()
 
 	hData := &sharedHandlerData{
 		done:  make(chan struct{}),
 		cache: config.CacheOthers,
 	}
 	if config.HandlingOption != NoHandling {
+		s.missChan = s.core.Subscribe()
 		s.handleData = hData
+		s.progress = newProgress(initial)
 	}
 	switch config.HandlingOption {
 	case SourceCentricHandling:
@@ -100,48 +153,86 @@ func newSharedSync(app *eng.Engine, config *SharedConfig, constants *Constants)
 	default:
 	}
 
-	return s
+	return s, nil
 }
 
-func (s *sharedSync) Listen() {
+func (s *sharedSync) Listen(ctx context.Context) error {
+	if s.isListening {
+		return ErrAlreadyListening
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	dataPrefix := append(s.groupPrefix, s.constants.DataComponent)
 	err := s.app.AttachHandler(dataPrefix, s.onInterest)
 	if err != nil {
-		s.logger.Errorf("Unable to register handler: %+v", err)
-		return
+		return fmt.Errorf("unable to register handler: %w", err)
 	}
-	err = s.app.RegisterRoute(dataPrefix)
+	err = registerRoute(ctx, s.app, dataPrefix)
 	if err != nil {
-		s.logger.Errorf("Unable to register route: %+v", err)
-		return
+		s.app.DetachHandler(dataPrefix)
+		return fmt.Errorf("unable to register route: %w", err)
+	}
+	err = s.core.Listen(ctx)
+	if err != nil {
+		s.app.DetachHandler(dataPrefix)
+		unregisterRoute(ctx, s.app, dataPrefix)
+		return err
 	}
 	s.isListening = true
 	s.logger.Info("Data-side Registered and Handled.")
-	s.core.Listen()
+	return nil
 }
 
-func (s *sharedSync) Activate(immediateStart bool) {
-	s.core.Activate(immediateStart)
+func (s *sharedSync) Activate(ctx context.Context, immediateStart bool) error {
+	err := s.core.Activate(ctx, immediateStart)
+	if err != nil {
+		return err
+	}
 	s.logger.Info("Sync Activated.")
+	return nil
 }
 
-func (s *sharedSync) Shutdown() {
-	s.core.Shutdown()
+func (s *sharedSync) Shutdown(ctx context.Context) error {
+	var errs []error
+	err := s.core.Shutdown(ctx)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	err = storeStateVector(s.storage, s.progress.limit(s.core.StateVector()))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("unable to persist state vector: %w", err))
+	}
 	if s.isListening {
 		dataPrefix := append(s.groupPrefix, s.constants.DataComponent)
-		err := s.app.DetachHandler(dataPrefix)
+		err = s.app.DetachHandler(dataPrefix)
 		if err != nil {
-			s.logger.Errorf("Detech handler error: %+v", err)
+			errs = append(errs, fmt.Errorf("detach handler error: %w", err))
 		}
-		err = s.app.UnregisterRoute(dataPrefix)
+		err = unregisterRoute(ctx, s.app, dataPrefix)
 		if err != nil {
-			s.logger.Errorf("Unregister route error: %+v", err)
+			errs = append(errs, fmt.Errorf("unregister route error: %w", err))
 		}
+		s.isListening = false
 	}
+	handled := true
 	if s.handleData != nil {
-		<-s.handleData.done
+		err = waitContext(ctx, s.handleData.done)
+		if err != nil {
+			errs = append(errs, err)
+			handled = false
+		}
+	}
+	if s.compactor != nil {
+		s.compactor.stop()
+	}
+	// Storage is left open while the handling routine may still write to it.
+	if s.ownStorage && handled {
+		s.storage.Close()
+		s.ownStorage = false
 	}
 	s.logger.Info("Sync Shutdown.")
+	return errors.Join(errs...)
 }
 
 func (s *sharedSync) NeedData(source enc.Name, seqno uint64, cache bool) {
@@ -151,34 +242,80 @@ func (s *sharedSync) NeedData(source enc.Name, seqno uint64, cache bool) {
 		retries: s.constants.DataInterestRetries,
 		cache:   cache,
 	}
-	if s.constants.MaxConcurrentDataInterests == 0 || atomic.LoadInt32(s.numFetches) < s.constants.MaxConcurrentDataInterests {
-		atomic.AddInt32(s.numFetches, 1)
-		s.sendInterest(i)
-		return
-	}
-	s.fetchQueue <- i
+	s.fetchMtx.Lock()
+	s.fetchPolicy.Push(FetchItem{Source: source, Seqno: seqno, ref: i})
+	s.metrics.queued.Add(1)
+	s.fetchMtx.Unlock()
+	s.processQueue()
+}
+
+func (s *sharedSync) PublishData(content []byte) (uint64, error) {
+	_, seqno, err := s.PublishDataWithName(content)
+	return seqno, err
+}
+
+// Returns the name of the publication, segments are named under it.
+func (s *sharedSync) PublishDataWithName(content []byte) (enc.Name, uint64, error) {
+	return s.publish(s.srcName, content, nil)
+}
+
+// The mapping is served to nodes deciding whether to fetch the publication.
+func (s *sharedSync) PublishDataWithMapping(content []byte, mapping enc.Name) (uint64, error) {
+	_, seqno, err := s.publish(s.srcName, content, mapping)
+	return seqno, err
+}
+
+// The dataset must be the source or named under it.
+func (s *sharedSync) PublishDataTo(dataset enc.Name, content []byte) (enc.Name, uint64, error) {
+	return s.publish(dataset, content, nil)
 }
 
-func (s *sharedSync) PublishData(content []byte) {
-	s.srcSeq++
-	name := s.getDataName(s.srcName, s.srcSeq)
-	wire, _, err := s.app.Spec().MakeData(
-		name,
-		s.datCfg,
-		enc.Wire{content},
-		sec.NewSha256Signer())
+func (s *sharedSync) publish(dataset enc.Name, content []byte, mapping enc.Name) (enc.Name, uint64, error) {
+	if !s.srcName.IsPrefix(dataset) {
+		return nil, 0, ErrForeignDataset
+	}
+	own := len(dataset) == len(s.srcName)
+	seqno := s.srcSeq + 1
+	if !own {
+		s.core.StateVector().RLock()
+		seqno = s.core.StateVector().Get(dataset.String()) + 1
+		s.core.StateVector().RUnlock()
+	}
+	name := s.getDataName(dataset, seqno)
+	names, wires, err := makePublication(s.app.Spec(), name, s.datCfg, s.signer, content, s.constants.MaxSegmentSize, s.constants.MaxSegments)
 	if err != nil {
-		s.logger.Errorf("unable to encode data: %+v", err)
-		return
+		return nil, 0, fmt.Errorf("unable to encode data: %w", err)
 	}
-	bytes := wire.Join()
-	if len(bytes) > 8800 {
-		s.logger.Warn("publication too large to be published")
-		return
+	for i := range names {
+		err = s.storage.Set(names[i].Bytes(), wires[i])
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to store data: %w", err)
+		}
+		if s.compactor != nil {
+			s.compactor.track(names[i], len(wires[i]))
+		}
+	}
+	if mapping != nil {
+		err = s.storage.Set(mappingKey(name), mapping.Bytes())
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to store mapping: %w", err)
+		}
 	}
 	s.logger.Info("Publishing data " + name.String())
-	s.storage.Set(name.Bytes(), bytes)
-	s.core.Update(s.srcName, s.srcSeq)
+	if own {
+		s.srcSeq = seqno
+		err = storeSequence(s.storage, seqno)
+		if err != nil {
+			s.logger.Errorf("unable to persist seqno: %+v", err)
+		}
+	}
+	s.progress.done(dataset.String(), seqno)
+	s.core.Update(dataset, seqno)
+	err = storeStateVector(s.storage, s.progress.limit(s.core.StateVector()))
+	if err != nil {
+		s.logger.Errorf("unable to persist state vector: %+v", err)
+	}
+	return name, seqno, nil
 }
 
 func (s *sharedSync) FeedInterest(interest ndn.Interest, rawInterest enc.Wire, sigCovered enc.Wire, reply ndn.ReplyFunc, deadline time.Time) {
@@ -190,45 +327,145 @@ func (s *sharedSync) Core() Core {
 }
 
 func (s *sharedSync) sendInterest(item *sharedFetchItem) {
-	wire, _, finalName, err := s.app.Spec().MakeInterest(s.getDataName(item.source, item.seqno), s.intCfg, nil, nil)
+	wire, _, finalName, err := s.app.Spec().MakeInterest(item.segs.interestName(s.getDataName(item.source, item.seqno)), s.intCfg, nil, nil)
 	if err != nil {
 		s.logger.Errorf("Unable to make Interest: %+v", err)
+		s.fetchFailed(item, ndn.InterestResultError, 0)
+		s.fetchDone(item)
 		return
 	}
 	err = s.app.Express(finalName, s.intCfg, wire,
-		func(result ndn.InterestResult, data ndn.Data, rawData, sigCovered enc.Wire, nackReason uint64) {
-			if result == ndn.InterestResultData || result == ndn.InterestResultNack || item.retries == 0 {
-				if item.cache && result == ndn.InterestResultData {
-					s.storage.Set(finalName.Bytes(), rawData.Join())
+		detached(func(result ndn.InterestResult, data ndn.Data, rawData, sigCovered enc.Wire, nackReason uint64) {
+			s.fetchFeedback(item, result)
+			switch {
+			case result == ndn.InterestResultData:
+				if !s.checker.check(item.source, data, sigCovered) {
+					s.logger.Warnf("Received unverifiable data %s", finalName)
+					s.metrics.invalid.Add(1)
+					if s.invalidCall != nil {
+						s.calls.run(func() { s.invalidCall(item.source, item.seqno, data) })
+					}
+					break
 				}
-				s.dataCall(item.source, item.seqno, data)
-				atomic.AddInt32(s.numFetches, -1)
-				s.processQueue()
-			} else {
+				done, err := item.segs.add(data, s.constants.MaxSegments)
+				if err != nil {
+					s.logger.Warnf("Received unexpected segment %s: %+v", data.Name(), err)
+					s.metrics.invalid.Add(1)
+					if s.invalidCall != nil {
+						s.calls.run(func() { s.invalidCall(item.source, item.seqno, data) })
+					}
+					break
+				}
+				if item.cache {
+					raw := rawData.Join()
+					s.storage.Set(data.Name().Bytes(), raw)
+					if s.compactor != nil {
+						s.compactor.track(data.Name(), len(raw))
+					}
+				}
+				if !done {
+					item.retries = s.constants.DataInterestRetries
+					s.sendInterest(item)
+					return
+				}
+				s.metrics.fetched.Add(1)
+				s.metrics.latency.Observe(s.constants.Clock.Now().Sub(item.started).Seconds())
+				data = item.segs.data(s.getDataName(item.source, item.seqno))
+				s.calls.run(func() { s.dataCall(item.source, item.seqno, data) })
+			case result == ndn.InterestResultNack || item.retries == 0:
+				s.fetchFailed(item, result, nackReason)
+			default:
 				item.retries--
+				s.metrics.retries.Add(1)
 				s.sendInterest(item)
+				return
 			}
-		})
+			s.fetchDone(item)
+		}))
 	if err != nil {
 		s.logger.Errorf("Unable to send Interest: %+v", err)
+		s.fetchFailed(item, ndn.InterestResultError, 0)
+		s.fetchDone(item)
 		return
 	}
+	s.metrics.interests.Add(1)
+}
+
+func (s *sharedSync) fetchFailed(item *sharedFetchItem, result ndn.InterestResult, nackReason uint64) {
+	ferr := FetchError{
+		Result:     result,
+		NackReason: nackReason,
+		Retries:    s.constants.DataInterestRetries - item.retries,
+	}
+	s.metrics.failures.Add(1)
+	if s.errorCall != nil {
+		s.calls.run(func() { s.errorCall(item.source, item.seqno, ferr) })
+	} else {
+		s.logger.Warnf("Unable to fetch %s:%d: %+v", item.source, item.seqno, ferr)
+	}
 }
 
 func (s *sharedSync) processQueue() {
-	if s.constants.MaxConcurrentDataInterests == 0 || atomic.LoadInt32(s.numFetches) < s.constants.MaxConcurrentDataInterests {
-		select {
-		case f := <-s.fetchQueue:
-			atomic.AddInt32(s.numFetches, 1)
-			s.sendInterest(f)
-			return
-		default:
+	var (
+		ready   []*sharedFetchItem
+		dropped int
+	)
+	s.fetchMtx.Lock()
+	for w := s.fetchPolicy.Window(); w <= 0 || s.numFetches < w; w = s.fetchPolicy.Window() {
+		f, ok := s.fetchPolicy.Pop()
+		if !ok {
+			break
 		}
+		item, ok := f.ref.(*sharedFetchItem)
+		if !ok {
+			s.logger.Errorf("FetchPolicy returned an item it was not given: %s:%d", f.Source, f.Seqno)
+			dropped++
+			continue
+		}
+		s.numFetches++
+		ready = append(ready, item)
+	}
+	// gauges are changed rather than set, as Syncs may share them
+	s.metrics.queued.Add(-float64(len(ready) + dropped))
+	s.metrics.fetches.Add(float64(len(ready)))
+	s.fetchMtx.Unlock()
+	now := s.constants.Clock.Now()
+	for _, item := range ready {
+		item.started = now
+		s.sendInterest(item)
 	}
 }
 
+func (s *sharedSync) fetchDone(item *sharedFetchItem) {
+	s.progress.done(item.source.String(), item.seqno)
+	s.fetchMtx.Lock()
+	s.numFetches--
+	s.metrics.fetches.Add(-1)
+	s.fetchMtx.Unlock()
+	s.processQueue()
+}
+
+func (s *sharedSync) fetchFeedback(item *sharedFetchItem, result ndn.InterestResult) {
+	f := FetchItem{Source: item.source, Seqno: item.seqno, ref: item}
+	s.fetchMtx.Lock()
+	switch result {
+	case ndn.InterestResultData:
+		s.fetchPolicy.OnData(f)
+	case ndn.InterestResultNack, ndn.InterestResultTimeout:
+		s.fetchPolicy.OnTimeout(f)
+	}
+	s.fetchMtx.Unlock()
+}
+
 func (s *sharedSync) onInterest(interest ndn.Interest, rawInterest enc.Wire, sigCovered enc.Wire, reply ndn.ReplyFunc, deadline time.Time) {
+	if base, start, end, ok := parseMappingName(interest.Name(), s.constants.MappingComponent); ok {
+		s.onMappingInterest(interest.Name(), base, start, end, reply)
+		return
+	}
 	dataPkt := s.storage.Get(interest.Name().Bytes())
+	if dataPkt == nil && interest.CanBePrefix() {
+		dataPkt = s.storage.Get(segmentName(interest.Name(), 0).Bytes())
+	}
 	if dataPkt != nil {
 		s.logger.Info("Serving data " + interest.Name().String())
 		err := reply(enc.Wire{dataPkt})
@@ -236,7 +473,68 @@ func (s *sharedSync) onInterest(interest ndn.Interest, rawInterest enc.Wire, sig
 			s.logger.Errorf("unable to reply with data: %+v", err)
 			return
 		}
+		s.metrics.served.Add(1)
+	}
+}
+
+func (s *sharedSync) onMappingInterest(name enc.Name, base enc.Name, start uint64, end uint64, reply ndn.ReplyFunc) {
+	mapping := loadMapping(s.storage, base, start, end, s.constants.MappingBatchSize)
+	wire, _, err := s.app.Spec().MakeData(name, s.datCfg, encodeMapping(mapping), s.signer)
+	if err != nil {
+		s.logger.Errorf("unable to encode mapping: %+v", err)
+		return
+	}
+	err = reply(wire)
+	if err != nil {
+		s.logger.Errorf("unable to reply with mapping: %+v", err)
+	}
+}
+
+// The callback receives every mapping found within the range, seqnos without one are left out.
+func (s *sharedSync) FetchMapping(source enc.Name, start uint64, end uint64, callback func(map[uint64]enc.Name, error)) {
+	f := &mappingFetch{
+		app:      s.app,
+		intCfg:   s.intCfg,
+		checker:  s.checker,
+		source:   source,
+		retries:  s.constants.DataInterestRetries,
+		mapping:  make(map[uint64]enc.Name),
+		callback: callback,
+	}
+	dataName := func(seqno uint64) enc.Name { return s.getDataName(source, seqno) }
+	f.start(mappingNames(dataName, s.constants.MappingComponent, start, end, s.constants.MappingBatchSize))
+}
+
+// Only publications passing the MappingFilter are fetched, a nil mapping means none was found.
+func (s *sharedSync) needFiltered(m MissingData, data *sharedHandlerData) {
+	s.FetchMapping(m.Dataset, m.StartSeq, m.EndSeq, func(mapping map[uint64]enc.Name, err error) {
+		if err != nil {
+			s.logger.Warnf("Unable to fetch mapping of %s: %+v", m.Dataset, err)
+		}
+		for seqno := m.StartSeq; seqno <= m.EndSeq && seqno >= m.StartSeq; seqno++ {
+			if s.mappingFilter(m.Dataset, seqno, mapping[seqno]) {
+				s.NeedData(m.Dataset, seqno, data.cache)
+			} else {
+				s.progress.done(m.Dataset.String(), seqno)
+			}
+		}
+	})
+}
+
+// Datasets under the source were published through PublishDataTo.
+func sharedSelfDatasets(initial *StateVector, source enc.Name, srcSeq uint64) []enc.Name {
+	var ret []enc.Name
+	if srcSeq > 0 {
+		ret = append(ret, source)
 	}
+	if initial != nil {
+		for p := initial.Entries().Front(); p != nil; p = p.Next() {
+			if len(p.Kname) > len(source) && source.IsPrefix(p.Kname) {
+				ret = append(ret, p.Kname)
+			}
+		}
+	}
+	return ret
 }
 
 func (s *sharedSync) getDataName(source enc.Name, seqno uint64) enc.Name {
@@ -252,10 +550,14 @@ func (s *sharedSync) newSourceCentricHandling(data *sharedHandlerData) {
 			select {
 			case missing, ok := <-s.missChan:
 				if !ok {
-					data.done <- struct{}{}
+					close(data.done)
 					return
 				}
 				for _, m := range missing {
+					if s.mappingFilter != nil {
+						s.needFiltered(m, data)
+						continue
+					}
 					for m.StartSeq <= m.EndSeq {
 						s.NeedData(m.Dataset, m.StartSeq, data.cache)
 						m.StartSeq++
@@ -273,9 +575,15 @@ func (s *sharedSync) newEqualTrafficHandling(data *sharedHandlerData) {
 			select {
 			case missing, ok := <-s.missChan:
 				if !ok {
-					data.done <- struct{}{}
+					close(data.done)
 					return
 				}
+				if s.mappingFilter != nil {
+					for _, m := range missing {
+						s.needFiltered(m, data)
+					}
+					continue
+				}
 				for {
 					allFetched = true
 					for _, m := range missing {
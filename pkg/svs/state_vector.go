@@ -24,7 +24,16 @@ func CopyStateVector(sv StateVector) *StateVector {
 	return &StateVector{sv.entries.Copy(), make(map[string]time.Time), &sync.RWMutex{}}
 }
 
+// A compressed vector is recognized by its type, whichever encoding is expected otherwise.
 func ParseStateVector(reader enc.ParseReader, formal bool) (*StateVector, error) {
+	if reader != nil {
+		if b, err := reader.ReadByte(); err == nil {
+			reader.UnreadByte()
+			if enc.TLNum(b) == TypeCompressedVector {
+				return parseCompressedStateVector(reader)
+			}
+		}
+	}
 	if formal {
 		return parseFormalStateVector(reader)
 	} else {
@@ -65,11 +74,40 @@ func (sv *StateVector) Sum() uint64 {
 	return ret
 }
 
-func (sv *StateVector) Update(dsstr string)                { sv.times[dsstr] = time.Now() }
+func (sv *StateVector) Update(dsstr string, at time.Time)  { sv.times[dsstr] = at }
 func (sv *StateVector) LastUpdated(dsstr string) time.Time { return sv.times[dsstr] }
 func (sv *StateVector) Len() int                           { return sv.entries.Len() }
 func (sv *StateVector) Entries() *nm.NameMap[uint64]       { return sv.entries }
 
+// Partial keeps the recent front entries and up to rotating of the remaining ones,
+// starting from offset and wrapping around.
+func (sv *StateVector) Partial(recent int, rotating int, offset int) *StateVector {
+	ret := NewStateVector()
+	p := sv.entries.Front()
+	for i := 0; p != nil && i < recent; i++ {
+		ret.Set(p.Kstr, p.Kname, p.Val, true)
+		p = p.Next()
+	}
+	older := sv.entries.Len() - ret.Len()
+	if older <= 0 || rotating <= 0 {
+		return ret
+	}
+	if rotating > older {
+		rotating = older
+	}
+	start := p
+	for i := 0; i < offset%older; i++ {
+		p = p.Next()
+	}
+	for i := 0; i < rotating; i++ {
+		ret.Set(p.Kstr, p.Kname, p.Val, true)
+		if p = p.Next(); p == nil {
+			p = start
+		}
+	}
+	return ret
+}
+
 func (sv *StateVector) Encode(formal bool) enc.Wire {
 	if formal {
 		tl, ls := sv.formalEncodingLengths()
@@ -104,6 +142,78 @@ func (sv *StateVector) Encode(formal bool) enc.Wire {
 	}
 }
 
+// Each entry holds the number of name components shared with the previous entry,
+// the remaining components, and the zigzag delta from the previous seqno.
+func (sv *StateVector) EncodeCompressed() enc.Wire {
+	tl := sv.compressedEncodingLength()
+	// length
+	pos := TypeCompressedVector.EncodingLength()
+	pos += enc.TLNum(tl).EncodingLength()
+	pos += tl
+	// space
+	ret := make(enc.Wire, 1)
+	ret[0] = make([]byte, pos)
+	buf := ret[0]
+	// encode
+	pos = TypeCompressedVector.EncodeInto(buf)
+	pos += enc.TLNum(tl).EncodeInto(buf[pos:])
+	sv.compressedEncodeInto(buf[pos:])
+	return ret
+}
+
+func (sv *StateVector) compressedEncodingLength() int {
+	var (
+		e, shared, sl int
+		prev          enc.Name
+		prevSeq       uint64
+	)
+	for p := sv.entries.Front(); p != nil; p = p.Next() {
+		shared = sharedComponents(prev, p.Kname)
+		sl = p.Kname[shared:].EncodingLength()
+		e += enc.TLNum(shared).EncodingLength()
+		e += enc.TLNum(sl).EncodingLength()
+		e += sl
+		e += enc.TLNum(zigzag(p.Val - prevSeq)).EncodingLength()
+		prev, prevSeq = p.Kname, p.Val
+	}
+	return e
+}
+
+func (sv *StateVector) compressedEncodeInto(buf []byte) int {
+	var (
+		pos, shared int
+		prev        enc.Name
+		prevSeq     uint64
+	)
+	for p := sv.entries.Front(); p != nil; p = p.Next() {
+		shared = sharedComponents(prev, p.Kname)
+		// name
+		pos += enc.TLNum(shared).EncodeInto(buf[pos:])
+		pos += enc.TLNum(p.Kname[shared:].EncodingLength()).EncodeInto(buf[pos:])
+		pos += p.Kname[shared:].EncodeInto(buf[pos:])
+		// seqno
+		pos += enc.TLNum(zigzag(p.Val - prevSeq)).EncodeInto(buf[pos:])
+		prev, prevSeq = p.Kname, p.Val
+	}
+	return pos
+}
+
+func sharedComponents(a enc.Name, b enc.Name) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i].Equal(b[i]) {
+		i++
+	}
+	return i
+}
+
+func zigzag(delta uint64) uint64 {
+	return uint64(int64(delta)<<1 ^ int64(delta)>>63)
+}
+
+func unzigzag(z uint64) uint64 {
+	return uint64(int64(z>>1) ^ -int64(z&1))
+}
+
 func (sv *StateVector) formalEncodingLengths() (int, []int) {
 	var (
 		e, tl, nl, i int
@@ -337,3 +447,66 @@ func parseInformalStateVector(reader enc.ParseReader) (*StateVector, error) {
 	}
 	return ret, nil
 }
+
+func parseCompressedStateVector(reader enc.ParseReader) (*StateVector, error) {
+	var (
+		dsname, prev enc.Name
+		suffix       enc.Name
+		seqno        uint64
+		shared, l, t enc.TLNum
+		end          int
+		err          error
+		ret          *StateVector = NewStateVector()
+	)
+	// vector
+	t, err = enc.ReadTLNum(reader)
+	if err != nil {
+		return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+	}
+	if t != TypeCompressedVector {
+		return ret, enc.ErrUnrecognizedField{TypeNum: t}
+	}
+	l, err = enc.ReadTLNum(reader)
+	if err != nil {
+		return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+	}
+	// lengths are untrusted, so they are compared before being converted
+	if uint64(l) > uint64(reader.Length()-reader.Pos()) {
+		return ret, enc.ErrFailToParse{TypeNum: t}
+	}
+	// entries
+	end = reader.Pos() + int(l)
+	for reader.Pos() < end {
+		// dsname
+		shared, err = enc.ReadTLNum(reader)
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		if uint64(shared) > uint64(len(prev)) {
+			return ret, enc.ErrFailToParse{TypeNum: t}
+		}
+		l, err = enc.ReadTLNum(reader)
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		if uint64(l) > uint64(end-reader.Pos()) {
+			return ret, enc.ErrFailToParse{TypeNum: t}
+		}
+		suffix, err = enc.ReadName(reader.Delegate(int(l)))
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		dsname = make(enc.Name, 0, int(shared)+len(suffix))
+		dsname = append(append(dsname, prev[:shared]...), suffix...)
+		// seqno
+		l, err = enc.ReadTLNum(reader)
+		if err != nil {
+			return ret, enc.ErrFailToParse{TypeNum: t, Err: err}
+		}
+		seqno += unzigzag(uint64(l))
+		// add
+		ret.Set(dsname.String(), dsname, seqno, true)
+		prev = dsname
+	}
+	return ret, nil
+}
@@ -1,109 +1,288 @@
 package svs
 
 import (
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
 
 type Tracker interface {
 	Reset(string)
+	Leave(string)
 	Detect()
+	NextExpiry() (time.Duration, bool)
 	Status(string) Status
+	Payload(string) []byte
+	SetPayload(string, uint64, []byte)
+	Members() []Member
+	Alive() []Member
 	UntilBeat() time.Duration
-	Chan() chan StatusChange
+	Chan() <-chan StatusChange
+	Subscribe(uint, OverflowPolicy) *StatusSubscription
+	Unsubscribe(*StatusSubscription)
+	Close()
+}
+
+// Member is a snapshot of a node known to the Tracker.
+type Member struct {
+	Node     string
+	Status   Status
+	LastBeat time.Time
+	Beats    uint
+	Payload  []byte
+}
+
+// StatusSubscription receives every StatusChange on C until it is unsubscribed.
+type StatusSubscription struct {
+	C  <-chan StatusChange
+	mb *mailbox[StatusChange]
+}
+
+// Returns the number of StatusChanges lost to the overflow policy.
+func (s *StatusSubscription) Dropped() uint64 {
+	return s.mb.lost()
 }
 
 type heart struct {
 	lastBeat time.Time
 	status   Status
 	beats    uint
+	payload  []byte
+	version  uint64
 }
 
 type tracker struct {
-	entries   sync.Map
+	entries   map[string]*heart
+	mtx       sync.RWMutex
 	constants *Constants
-	statChan  chan StatusChange
+	subs      []*StatusSubscription
+	main      *StatusSubscription
 	selfHrt   *heart
 }
 
 func NewTracker(src string, cs *Constants) Tracker {
 	t := &tracker{
+		entries:   make(map[string]*heart),
 		constants: cs,
-		statChan:  make(chan StatusChange, cs.InitialStatusChangeChannelSize),
 	}
+	t.main = t.Subscribe(cs.InitialStatusChangeChannelSize, DropOldest)
 	hrt := &heart{status: Expired}
-	t.entries.Store(src, hrt)
+	t.entries[src] = hrt
 	t.selfHrt = hrt
-	t.statChan <- StatusChange{Node: src, OldStatus: Unseen, NewStatus: Expired}
+	t.notify(StatusChange{Node: src, OldStatus: Unseen, NewStatus: Expired})
 	return t
 }
 
 func (t *tracker) Reset(src string) {
-	hrt, ok := t.entries.Load(src)
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	hrt, ok := t.entries[src]
 	if !ok {
-		t.entries.Store(src, &heart{status: Expired})
-		t.statChan <- StatusChange{Node: src, OldStatus: Unseen, NewStatus: Expired}
+		t.entries[src] = &heart{status: Expired, lastBeat: t.constants.Clock.Now()}
+		t.notify(StatusChange{Node: src, OldStatus: Unseen, NewStatus: Expired})
 		return
 	}
-	t.resetHeart(src, hrt.(*heart))
+	t.resetHeart(src, hrt)
 }
 
 func (t *tracker) resetHeart(src string, hrt *heart) {
-	hrt.lastBeat = time.Now()
+	now := t.constants.Clock.Now()
+	last := hrt.lastBeat
+	hrt.lastBeat = now
 	if hrt.status == Renewed {
 		hrt.beats = 0
 		return
 	}
+	// beats towards renewal must not be further apart than TrackRate
+	if now.Sub(last) > t.constants.TrackRate {
+		hrt.beats = 0
+	}
 	hrt.beats++
 	if hrt.beats >= t.constants.HeartbeatsToRenew {
+		old := hrt.status
 		hrt.beats = 0
 		hrt.status = Renewed
-		t.statChan <- StatusChange{Node: src, OldStatus: Expired, NewStatus: Renewed}
+		t.notify(StatusChange{Node: src, OldStatus: old, NewStatus: Renewed})
 	}
 }
 
+// A node that left is renewed again through its heartbeats.
+func (t *tracker) Leave(src string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	hrt, ok := t.entries[src]
+	if !ok || hrt == t.selfHrt || hrt.status == Left {
+		return
+	}
+	old := hrt.status
+	hrt.beats = 0
+	hrt.status = Left
+	t.notify(StatusChange{Node: src, OldStatus: old, NewStatus: Left})
+}
+
 func (t *tracker) Detect() {
 	var (
-		currentTime = time.Now()
+		currentTime = t.constants.Clock.Now()
 		tp          time.Duration
-		src         string
-		hrt         *heart
 	)
-	t.entries.Range(func(key, value any) bool {
-		src = key.(string)
-		hrt = value.(*heart)
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for src, hrt := range t.entries {
 		if hrt == t.selfHrt {
-			return true
+			continue
 		}
 		tp = currentTime.Sub(hrt.lastBeat)
-		if tp > t.constants.TrackRate {
-			if hrt.status != Renewed {
+		if hrt.status == Renewed && tp > t.constants.TrackRate {
+			hrt.beats = uint(tp / t.constants.TrackRate)
+			if hrt.beats >= t.constants.HeartbeatsToExpire {
 				hrt.beats = 0
-			} else {
-				hrt.beats = uint(tp / t.constants.TrackRate)
-				if hrt.beats >= t.constants.HeartbeatsToExpire {
-					hrt.beats = 0
-					hrt.status = Expired
-					t.statChan <- StatusChange{Node: src, OldStatus: Renewed, NewStatus: Expired}
-				}
+				hrt.status = Expired
+				t.notify(StatusChange{Node: src, OldStatus: Renewed, NewStatus: Expired})
 			}
 		}
-		return true
-	})
+	}
+}
+
+// Returns how long until Detect would expire a node, false if no node is renewed.
+func (t *tracker) NextExpiry() (time.Duration, bool) {
+	var (
+		next  time.Time
+		found bool
+		after = time.Duration(max(t.constants.HeartbeatsToExpire, 1)) * t.constants.TrackRate
+	)
+	t.mtx.RLock()
+	for _, hrt := range t.entries {
+		if hrt == t.selfHrt || hrt.status != Renewed {
+			continue
+		}
+		if at := hrt.lastBeat.Add(after); !found || at.Before(next) {
+			next, found = at, true
+		}
+	}
+	t.mtx.RUnlock()
+	if !found {
+		return 0, false
+	}
+	return next.Sub(t.constants.Clock.Now()), true
 }
 
 func (t *tracker) UntilBeat() time.Duration {
-	return time.Until(t.selfHrt.lastBeat.Add(t.constants.HeartbeatRate))
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return t.selfHrt.lastBeat.Add(t.constants.HeartbeatRate).Sub(t.constants.Clock.Now())
 }
 
 func (t *tracker) Status(src string) Status {
-	hrt, ok := t.entries.Load(src)
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	hrt, ok := t.entries[src]
 	if !ok {
 		return Unseen
 	}
-	return hrt.(*heart).status
+	return hrt.status
 }
 
-func (t *tracker) Chan() chan StatusChange {
-	return t.statChan
+func (t *tracker) Payload(src string) []byte {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	hrt, ok := t.entries[src]
+	if !ok {
+		return nil
+	}
+	return hrt.payload
+}
+
+// Payloads older than the known version are ignored, unseen nodes are not added.
+func (t *tracker) SetPayload(src string, version uint64, payload []byte) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	hrt, ok := t.entries[src]
+	if !ok || version < hrt.version {
+		return
+	}
+	hrt.version = version
+	hrt.payload = payload
+}
+
+// Members are ordered by node name, including the node itself.
+func (t *tracker) Members() []Member {
+	return t.members(func(Status) bool { return true })
+}
+
+func (t *tracker) Alive() []Member {
+	return t.members(func(s Status) bool { return s == Renewed })
+}
+
+func (t *tracker) members(keep func(Status) bool) []Member {
+	ret := make([]Member, 0)
+	t.mtx.RLock()
+	for src, hrt := range t.entries {
+		if keep(hrt.status) {
+			ret = append(ret, Member{Node: src, Status: hrt.status, LastBeat: hrt.lastBeat, Beats: hrt.beats, Payload: hrt.payload})
+		}
+	}
+	t.mtx.RUnlock()
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Node < ret[j].Node })
+	return ret
+}
+
+// The default subscription, bounded by InitialStatusChangeChannelSize and dropping the oldest changes.
+func (t *tracker) Chan() <-chan StatusChange {
+	return t.main.C
+}
+
+// A size of 0 never overflows. Changes from before subscribing are not replayed, see Members.
+func (t *tracker) Subscribe(size uint, policy OverflowPolicy) *StatusSubscription {
+	overflow := dropOldest[StatusChange]
+	if policy == Coalesce {
+		overflow = coalesceStatus
+	}
+	mb := newMailbox(int(size), overflow)
+	sub := &StatusSubscription{C: mb.out, mb: mb}
+	t.mtx.Lock()
+	t.subs = append(t.subs, sub)
+	t.mtx.Unlock()
+	return sub
+}
+
+// The subscription's channel is closed.
+func (t *tracker) Unsubscribe(sub *StatusSubscription) {
+	t.mtx.Lock()
+	t.subs = slices.DeleteFunc(t.subs, func(s *StatusSubscription) bool { return s == sub })
+	t.mtx.Unlock()
+	sub.mb.close()
+}
+
+// Closes every subscription, including the default one.
+func (t *tracker) Close() {
+	t.mtx.Lock()
+	subs := t.subs
+	t.subs = nil
+	t.mtx.Unlock()
+	for _, sub := range subs {
+		sub.mb.close()
+	}
+}
+
+// Must be called with the lock held so that every subscriber sees changes in the same order.
+func (t *tracker) notify(change StatusChange) {
+	for _, sub := range t.subs {
+		sub.mb.push(change)
+	}
+}
+
+// A pending change of the same node absorbs the new one, otherwise the oldest is dropped.
+func coalesceStatus(queue []StatusChange, change StatusChange) ([]StatusChange, bool) {
+	for i := len(queue) - 1; i >= 0; i-- {
+		if queue[i].Node != change.Node {
+			continue
+		}
+		if queue[i].OldStatus == change.NewStatus {
+			return slices.Delete(queue, i, i+1), false
+		}
+		queue[i].NewStatus = change.NewStatus
+		return queue, false
+	}
+	return dropOldest(queue, change)
 }
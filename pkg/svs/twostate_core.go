@@ -1,6 +1,9 @@
 package svs
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"slices"
 	"sync/atomic"
 	"time"
@@ -9,7 +12,6 @@ import (
 	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
 	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
 	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
-	sec "github.com/zjkmxy/go-ndn/pkg/security"
 	utl "github.com/zjkmxy/go-ndn/pkg/utils"
 )
 
@@ -17,18 +19,26 @@ type twoStateCore struct {
 	app         *eng.Engine
 	state       *int32
 	constants   *Constants
-	subs        []chan SyncUpdate
+	subs        fanout
 	syncPrefix  enc.Name
 	selfsets    []string
 	local       *StateVector
 	record      *StateVector
 	scheduler   Scheduler
 	logger      *log.Entry
+	metrics     *coreMetrics
+	shaker      *shaker
+	events      eventEmitter
 	intCfg      *ndn.InterestConfig
+	signer      ndn.Signer
+	validator   Validator
 	formal      bool
+	compressed  bool
+	partial     bool
+	rotation    int
 	effSuppress bool
 	isListening bool
-	isActive    bool
+	isActive    atomic.Bool
 }
 
 func newTwoStateCore(app *eng.Engine, config *TwoStateCoreConfig, constants *Constants) *twoStateCore {
@@ -36,64 +46,93 @@ func newTwoStateCore(app *eng.Engine, config *TwoStateCoreConfig, constants *Con
 		app:        app,
 		state:      new(int32),
 		constants:  constants,
-		subs:       make([]chan SyncUpdate, 0),
 		syncPrefix: config.SyncPrefix,
-		selfsets:   make([]string, 0),
 		local:      NewStateVector(),
 		record:     NewStateVector(),
 		logger:     log.WithField("module", "svs"),
+		metrics:    newCoreMetrics(config.Metrics),
+		shaker:     newShaker(constants),
+		events:     eventEmitter{sink: config.EventSink, clock: constants.Clock},
 		intCfg: &ndn.InterestConfig{
 			MustBeFresh: true,
 			CanBePrefix: true,
 			Lifetime:    utl.IdPtr(constants.SyncInterestLifeTime),
 		},
 		formal:      config.FormalEncoding,
+		compressed:  config.CompressedEncoding,
+		partial:     config.PartialVector,
 		effSuppress: config.EfficientSuppression,
 	}
-	c.scheduler = NewScheduler(c.onTimer)
+	c.signer, c.validator = coreSecurity(config.Signer, config.Validator)
+	c.selfsets = restoreState(c.local, config.InitialState, config.SelfDatasets)
+	c.scheduler = NewScheduler(c.onTimer, constants.Clock)
 	c.scheduler.ApplyBounds(JitterToBounds(constants.SyncInterval, constants.SyncIntervalJitter))
 	return c
 }
 
-func (c *twoStateCore) Listen() {
+func (c *twoStateCore) Listen(ctx context.Context) error {
+	if c.isListening {
+		return ErrAlreadyListening
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	err := c.app.AttachHandler(c.syncPrefix, c.onInterest)
 	if err != nil {
-		c.logger.Errorf("Unable to register handler: %+v", err)
-		return
+		return fmt.Errorf("unable to register handler: %w", err)
 	}
-	err = c.app.RegisterRoute(c.syncPrefix)
+	err = registerRoute(ctx, c.app, c.syncPrefix)
 	if err != nil {
-		c.logger.Errorf("Unable to register route: %+v", err)
-		return
+		c.app.DetachHandler(c.syncPrefix)
+		return fmt.Errorf("unable to register route: %w", err)
 	}
 	c.isListening = true
 	c.logger.Info("Sync-side Registered and Handled.")
+	return nil
 }
 
-func (c *twoStateCore) Activate(immediateStart bool) {
+func (c *twoStateCore) Activate(ctx context.Context, immediateStart bool) error {
+	if c.isActive.Load() {
+		return ErrAlreadyActive
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	c.scheduler.Start(immediateStart)
-	c.isActive = true
+	c.isActive.Store(true)
 	c.logger.Info("Core Activated.")
+	return nil
 }
 
-func (c *twoStateCore) Shutdown() {
-	if c.isActive {
-		c.scheduler.Stop()
+func (c *twoStateCore) Shutdown(ctx context.Context) error {
+	var errs []error
+	if c.isActive.Load() {
+		// the scheduler is stopped regardless, ctx only bounds the wait
+		stopped := make(chan struct{})
+		go func() {
+			c.scheduler.Stop()
+			close(stopped)
+		}()
+		err := waitContext(ctx, stopped)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stop scheduler error: %w", err))
+		}
+		c.isActive.Store(false)
 	}
 	if c.isListening {
 		err := c.app.DetachHandler(c.syncPrefix)
 		if err != nil {
-			c.logger.Errorf("Detech handler error: %+v", err)
+			errs = append(errs, fmt.Errorf("detach handler error: %w", err))
 		}
-		err = c.app.UnregisterRoute(c.syncPrefix)
+		err = unregisterRoute(ctx, c.app, c.syncPrefix)
 		if err != nil {
-			c.logger.Errorf("Unregister route error: %+v", err)
+			errs = append(errs, fmt.Errorf("unregister route error: %w", err))
 		}
+		c.isListening = false
 	}
-	for _, sub := range c.subs {
-		close(sub)
-	}
+	c.subs.close()
 	c.logger.Info("Core Shutdown.")
+	return errors.Join(errs...)
 }
 
 func (c *twoStateCore) Update(dsname enc.Name, seqno uint64) {
@@ -102,29 +141,34 @@ func (c *twoStateCore) Update(dsname enc.Name, seqno uint64) {
 		return
 	}
 	dsstr := dsname.String()
-	if seqno <= c.local.Get(dsstr) {
+	c.local.Lock()
+	lVal := c.local.Get(dsstr)
+	if seqno <= lVal {
+		c.local.Unlock()
 		c.logger.Warn("The Core was updated with a non-new seqno.")
 		return
 	}
-	if c.local.Get(dsstr) == 0 {
+	if lVal == 0 {
 		c.selfsets = append(c.selfsets, dsstr)
-	} else {
-		if !slices.Contains(c.selfsets, dsstr) {
-			c.logger.Warn("The Core was updated with a dataset not previously updated by the node.")
-			return
-		}
+	} else if !slices.Contains(c.selfsets, dsstr) {
+		c.local.Unlock()
+		c.logger.Warn("The Core was updated with a dataset not previously updated by the node.")
+		return
 	}
-	c.local.Lock()
 	c.local.Set(dsstr, dsname, seqno, false)
-	c.local.Update(dsstr)
+	c.local.Update(dsstr, c.constants.Clock.Now())
 	c.local.Unlock()
-	c.scheduler.Skip()
+	if c.isActive.Load() {
+		c.scheduler.Skip()
+	}
+}
+
+func (c *twoStateCore) Subscribe() <-chan SyncUpdate {
+	return c.subs.subscribe()
 }
 
-func (c *twoStateCore) Subscribe() chan SyncUpdate {
-	ch := make(chan SyncUpdate, c.constants.InitialMissingChannelSize)
-	c.subs = append(c.subs, ch)
-	return ch
+func (c *twoStateCore) Unsubscribe(ch <-chan SyncUpdate) {
+	c.subs.unsubscribe(ch)
 }
 
 func (c *twoStateCore) StateVector() *StateVector {
@@ -136,50 +180,116 @@ func (c *twoStateCore) FeedInterest(interest ndn.Interest, rawInterest enc.Wire,
 }
 
 func (c *twoStateCore) onInterest(interest ndn.Interest, rawInterest enc.Wire, sigCovered enc.Wire, reply ndn.ReplyFunc, deadline time.Time) {
-	// TODO: VERIFY THE INTEREST
+	if !c.validator.Validate(interest.Name(), sigCovered, interest.Signature()) {
+		c.logger.Warn("Received unverifiable statevector.")
+		c.metrics.invalid.Add(1)
+		c.events.emit(Event{Type: InterestRejectedEvent, State: stateName(atomic.LoadInt32(c.state)), Reason: "unverifiable"})
+		return
+	}
 	remote, err := ParseStateVector(enc.NewWireReader(interest.AppParam()), c.formal)
 	if err != nil {
 		c.logger.Warnf("Received unparsable statevector: %+v", err)
+		c.metrics.unparsable.Add(1)
+		c.events.emit(Event{Type: InterestRejectedEvent, State: stateName(atomic.LoadInt32(c.state)), Reason: err.Error()})
 		return
 	}
-	if atomic.LoadInt32(c.state) == suppressionState {
-		c.recordVector(remote)
+	c.metrics.received.Add(1)
+	state := atomic.LoadInt32(c.state)
+	if c.events.enabled() {
+		c.events.emit(Event{Type: InterestReceivedEvent, State: stateName(state), Remote: vectorSnapshot(remote)})
+	}
+	// only vectors behind the local one are inconsistent, new publications merely spread
+	if state == suppressionState {
+		if c.recordVector(remote) && c.shaker.inconsistent() {
+			c.startShaking(state)
+		}
 		return
 	}
 	localNewer := c.mergeVectorToLocal(remote)
+	// while shaking, the shortened timer keeps exchanging vectors until they settle
+	if localNewer && c.shaker.inconsistent() && state != shakingState {
+		c.startShaking(state)
+		return
+	}
+	if state == shakingState {
+		return
+	}
 	if !localNewer {
+		c.metrics.suppressed.Add(1)
+		c.events.emit(Event{Type: InterestSuppressedEvent, State: stateName(state), Reason: "received vector is up to date"})
 		c.scheduler.Reset()
 	} else {
 		atomic.StoreInt32(c.state, suppressionState)
+		// the record keeps its own lock, which others may be waiting on
 		c.record.Lock()
-		c.record = remote
+		c.record.entries, c.record.times = remote.entries, remote.times
 		c.record.Unlock()
-		delay := suppressionDelay(c.constants.SuppressionInterval, c.constants.SuppressionIntervalJitter)
-		if c.scheduler.TimeLeft() > delay {
+		delay := suppressionDelay(c.constants.Clock, c.constants.SuppressionInterval, c.constants.SuppressionIntervalJitter)
+		if left := c.scheduler.TimeLeft(); left > delay {
 			c.scheduler.Set(delay)
+		} else {
+			delay = left
 		}
+		c.events.emit(Event{Type: SuppressionEnteredEvent, State: stateName(state), Delay: delay})
 	}
 }
 
+func (c *twoStateCore) startShaking(from int32) {
+	atomic.StoreInt32(c.state, shakingState)
+	c.scheduler.ApplyBounds(JitterToBounds(c.constants.ShakingInterval, c.constants.ShakingIntervalJitter))
+	c.scheduler.Reset()
+	c.metrics.shaking.Add(1)
+	c.events.emit(Event{Type: ShakingEnteredEvent, State: stateName(from), Reason: "burst of inconsistent vectors"})
+}
+
 func (c *twoStateCore) onTimer() {
-	if atomic.LoadInt32(c.state) == suppressionState {
+	state := atomic.LoadInt32(c.state)
+	c.events.emit(Event{Type: TimerFiredEvent, State: stateName(state)})
+	if state == suppressionState {
 		atomic.StoreInt32(c.state, steadyState)
 		if !c.isInterestNeeded() {
+			c.metrics.suppressed.Add(1)
+			if c.events.enabled() {
+				c.local.RLock()
+				c.record.RLock()
+				local, record := vectorSnapshot(c.local), vectorSnapshot(c.record)
+				c.record.RUnlock()
+				c.local.RUnlock()
+				c.events.emit(Event{Type: InterestSuppressedEvent, State: stateName(state), Local: local, Record: record, Reason: "vectors heard while suppressing cover the local vector"})
+			}
 			return
 		}
 	}
 	c.sendInterest()
+	if state == shakingState && c.shaker.round() {
+		// the scheduler picks the next interval after this returns
+		atomic.StoreInt32(c.state, steadyState)
+		c.scheduler.ApplyBounds(JitterToBounds(c.constants.SyncInterval, c.constants.SyncIntervalJitter))
+		c.events.emit(Event{Type: ShakingExitedEvent, State: stateName(state)})
+	}
 }
 
 func (c *twoStateCore) sendInterest() {
 	// make the interest
-	// TODO: SIGN THE INTEREST WITH AUTHENTICATABLE KEY
-	// WARNING: SHA SIGNER PROVIDES NOTHING (signature only includes the appParams) & IS ONLY PLACEHOLDER
 	c.local.RLock()
-	appP := c.local.Encode(c.formal)
+	sv := c.local
+	if c.partial {
+		sv = c.local.Partial(int(c.constants.PartialVectorRecent), int(c.constants.PartialVectorRotation), c.rotation)
+		c.rotation += int(c.constants.PartialVectorRotation)
+	}
+	var appP enc.Wire
+	if c.compressed {
+		appP = sv.EncodeCompressed()
+	} else {
+		appP = sv.Encode(c.formal)
+	}
+	var sent map[string]uint64
+	if c.events.enabled() {
+		sent = vectorSnapshot(sv)
+	}
 	c.local.RUnlock()
 	wire, _, finalName, err := c.app.Spec().MakeInterest(
-		c.syncPrefix, c.intCfg, appP, sec.NewSha256IntSigner(c.app.Timer()),
+		c.syncPrefix, c.intCfg, appP, c.signer,
 	)
 	if err != nil {
 		c.logger.Errorf("Unable to make Sync Interest: %+v", err)
@@ -193,6 +303,8 @@ func (c *twoStateCore) sendInterest() {
 		c.logger.Errorf("Unable to send Sync Interest: %+v", err)
 		return
 	}
+	c.metrics.sent.Add(1)
+	c.events.emit(Event{Type: InterestSentEvent, State: stateName(atomic.LoadInt32(c.state)), Local: sent})
 }
 
 func (c *twoStateCore) mergeVectorToLocal(vector *StateVector) bool {
@@ -207,67 +319,110 @@ func (c *twoStateCore) mergeVectorToLocal(vector *StateVector) bool {
 		if lVal < p.Val {
 			missing = append(missing, MissingData{Dataset: p.Kname, StartSeq: lVal + 1, EndSeq: p.Val})
 			c.local.Set(p.Kstr, p.Kname, p.Val, false)
-			c.local.Update(p.Kstr)
-		} else if lVal > p.Val {
-			if (c.effSuppress || slices.Contains(c.selfsets, p.Kstr)) && time.Since(c.local.LastUpdated(p.Kstr)) < c.constants.SuppressionInterval {
-				continue
-			}
+			c.local.Update(p.Kstr, c.constants.Clock.Now())
+		} else if c.isBehind(p.Kstr, lVal, p.Val) {
 			lNewer = true
+			if c.partial {
+				// make sure the entry is part of the next partial vector
+				c.local.Set(p.Kstr, p.Kname, lVal, false)
+			}
 		}
 	}
 	// Recently added datasets are not taken into account when checking length
-	if vector.Len() < c.local.Len() {
+	if !c.partial && vector.Len() < c.local.Len() {
 		lNewer = true
 	}
+	var merged map[string]uint64
+	if c.events.enabled() {
+		merged = vectorSnapshot(c.local)
+	}
 	c.local.Unlock()
+	if merged != nil {
+		c.events.emit(Event{Type: VectorMergedEvent, State: stateName(atomic.LoadInt32(c.state)), Local: merged, Missing: eventRanges(missing), Newer: lNewer})
+	}
 	if len(missing) != 0 {
-		for _, sub := range c.subs {
-			sub <- missing
-		}
+		c.metrics.found(missing)
+		c.subs.publish(missing)
 	}
 	return lNewer
 }
 
-func (c *twoStateCore) recordVector(vector *StateVector) {
-	var missing = make(SyncUpdate, 0)
+// Must be called with the local vector locked. Entries updated too recently to have spread are not behind.
+func (c *twoStateCore) isBehind(key string, lVal uint64, rVal uint64) bool {
+	if lVal <= rVal {
+		return false
+	}
+	if (c.effSuppress || slices.Contains(c.selfsets, key)) && c.constants.Clock.Now().Sub(c.local.LastUpdated(key)) < c.constants.SuppressionInterval {
+		return false
+	}
+	return true
+}
+
+// Reports whether the vector is behind the local one.
+func (c *twoStateCore) recordVector(vector *StateVector) bool {
+	var (
+		missing = make(SyncUpdate, 0)
+		behind  bool
+	)
+	c.local.Lock()
 	c.record.Lock()
 	for p := vector.Entries().Back(); p != nil; p = p.Prev() {
 		if c.record.Get(p.Kstr) < p.Val {
 			c.record.Set(p.Kstr, p.Kname, p.Val, true)
 		}
+		if c.isBehind(p.Kstr, c.local.Get(p.Kstr), p.Val) {
+			behind = true
+		}
 		if c.local.Get(p.Kstr) < p.Val {
 			missing = append(missing, MissingData{Dataset: p.Kname, StartSeq: c.local.Get(p.Kstr) + 1, EndSeq: p.Val})
 			c.local.Set(p.Kstr, p.Kname, p.Val, false)
-			c.local.Update(p.Kstr)
+			c.local.Update(p.Kstr, c.constants.Clock.Now())
 		}
 	}
+	if !c.partial && vector.Len() < c.local.Len() {
+		behind = true
+	}
+	var local, record map[string]uint64
+	if c.events.enabled() {
+		local, record = vectorSnapshot(c.local), vectorSnapshot(c.record)
+	}
 	c.record.Unlock()
+	c.local.Unlock()
+	if local != nil {
+		c.events.emit(Event{Type: VectorMergedEvent, State: stateName(suppressionState), Local: local, Record: record, Missing: eventRanges(missing)})
+	}
 	if len(missing) != 0 {
-		for _, sub := range c.subs {
-			sub <- missing
-		}
+		c.metrics.found(missing)
+		c.subs.publish(missing)
 	}
+	return behind
 }
 
 func (c *twoStateCore) isInterestNeeded() bool {
-	c.local.RLock()
+	c.local.Lock()
 	c.record.RLock()
-	defer c.local.RUnlock()
+	defer c.local.Unlock()
 	defer c.record.RUnlock()
-	if c.record.Len() < c.local.Len() {
+	if !c.partial && c.record.Len() < c.local.Len() {
 		return true
 	}
+	needed := false
 	for p := c.record.Entries().Front(); p != nil; p = p.Next() {
-		if c.local.Get(p.Kstr) > p.Val {
-			if (c.effSuppress || slices.Contains(c.selfsets, p.Kstr)) && time.Since(c.local.LastUpdated(p.Kstr)) < c.constants.SuppressionInterval {
+		if lVal := c.local.Get(p.Kstr); lVal > p.Val {
+			if (c.effSuppress || slices.Contains(c.selfsets, p.Kstr)) && c.constants.Clock.Now().Sub(c.local.LastUpdated(p.Kstr)) < c.constants.SuppressionInterval {
 				continue
 			}
-			return true
+			if !c.partial {
+				return true
+			}
+			c.local.Set(p.Kstr, p.Kname, lVal, false)
+			needed = true
 		}
 	}
-	return false
+	return needed
 }
 
-func suppressionDelay(val time.Duration, jitter float64) time.Duration {
-	return BoundedRand(JitterToBounds(val, jitter))
+func suppressionDelay(clock Clock, val time.Duration, jitter float64) time.Duration {
+	min, max := JitterToBounds(val, jitter)
+	return boundedRand(clock, min, max)
 }
@@ -0,0 +1,158 @@
+package metrics_test
+
+import (
+	"bufio"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	metrics "github.com/justincpresley/ndn-sync/util/metrics"
+	simnet "github.com/justincpresley/ndn-sync/util/simnet"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+)
+
+func TestRegistryText(t *testing.T) {
+	r := metrics.NewRegistry(map[string]string{"node": `/a"b`})
+	r.SetBuckets([]float64{1, 0.5})
+	r.Counter("requests_total", "Requests.\nServed.").Add(2)
+	r.Counter("requests_total", "").Add(1)
+	r.Gauge("depth", "").Set(4)
+	r.Gauge("depth", "").Add(-1.5)
+	h := r.Histogram("latency_seconds", "Latency.")
+	h.Observe(0.2)
+	h.Observe(0.7)
+	h.Observe(3)
+
+	var out strings.Builder
+	n, err := r.WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(out.Len()), n)
+	assert.Equal(t, `# TYPE depth gauge
+depth{node="/a\"b"} 2.5
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{node="/a\"b",le="0.5"} 1
+latency_seconds_bucket{node="/a\"b",le="1"} 2
+latency_seconds_bucket{node="/a\"b",le="+Inf"} 3
+latency_seconds_sum{node="/a\"b"} 3.9
+latency_seconds_count{node="/a\"b"} 3
+# HELP requests_total Requests.\nServed.
+# TYPE requests_total counter
+requests_total{node="/a\"b"} 3
+`, out.String())
+
+	assert.Panics(t, func() { r.Gauge("requests_total", "") })
+}
+
+func sample(r *metrics.Registry, name string) float64 {
+	var out strings.Builder
+	r.WriteTo(&out)
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == name {
+			val, _ := strconv.ParseFloat(fields[1], 64)
+			return val
+		}
+	}
+	return -1
+}
+
+func TestCoreMetrics(t *testing.T) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	cs := svs.GetDefaultConstants()
+	cs.SyncInterval = 200 * time.Millisecond
+	cs.SuppressionInterval = 20 * time.Millisecond
+	prefix, _ := enc.NameFromStr("/svs")
+	regs := []*metrics.Registry{metrics.NewRegistry(nil), metrics.NewRegistry(nil)}
+	cores := make([]svs.Core, len(regs))
+	for i, reg := range regs {
+		app, err := simnet.NewEngine(net.NewFace())
+		assert.NoError(t, err)
+		defer app.Shutdown()
+		cores[i] = svs.NewCore(app, &svs.TwoStateCoreConfig{SyncPrefix: prefix, Metrics: reg}, cs)
+		assert.NoError(t, cores[i].Listen(ctx))
+		assert.NoError(t, cores[i].Activate(ctx, true))
+		defer cores[i].Shutdown(ctx)
+	}
+
+	node, _ := enc.NameFromStr("/node0")
+	cores[0].Update(node, 3)
+	assert.Eventually(t, func() bool { return sample(regs[1], "svs_missing_publications_total") == 3 }, 5*time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, sample(regs[0], "svs_sync_interests_sent_total"), 1.0)
+	assert.GreaterOrEqual(t, sample(regs[1], "svs_sync_interests_received_total"), 1.0)
+	assert.Equal(t, 0.0, sample(regs[1], "svs_sync_interests_invalid_total"))
+}
+
+func TestSyncMetrics(t *testing.T) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	cs := svs.GetDefaultConstants()
+	cs.SyncInterval = 200 * time.Millisecond
+	cs.SuppressionInterval = 20 * time.Millisecond
+	group, _ := enc.NameFromStr("/svs")
+	regs := []*metrics.Registry{metrics.NewRegistry(nil), metrics.NewRegistry(nil)}
+	syncs := make([]svs.NativeSync, len(regs))
+	for i, reg := range regs {
+		app, err := simnet.NewEngine(net.NewFace())
+		assert.NoError(t, err)
+		defer app.Shutdown()
+		source, _ := enc.NameFromStr("/node" + strconv.Itoa(i))
+		config := svs.GetBasicNativeConfig(source, group, func(enc.Name, uint64, ndn.Data) {})
+		config.Storage = svs.NewMemoryDB(0)
+		config.Metrics = reg
+		syncs[i], err = svs.NewNativeSync(app, config, cs)
+		assert.NoError(t, err)
+		assert.NoError(t, syncs[i].Listen(ctx))
+		assert.NoError(t, syncs[i].Activate(ctx, true))
+		defer syncs[i].Shutdown(ctx)
+	}
+
+	syncs[0].PublishData([]byte("a"))
+	syncs[0].PublishData([]byte("b"))
+	assert.Eventually(t, func() bool { return sample(regs[1], "svs_publications_fetched_total") == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2.0, sample(regs[1], "svs_fetch_duration_seconds_count"))
+	assert.Equal(t, 0.0, sample(regs[1], "svs_fetch_queue_depth"))
+	assert.Equal(t, 0.0, sample(regs[1], "svs_fetches_outstanding"))
+	assert.GreaterOrEqual(t, sample(regs[1], "svs_data_interests_sent_total"), 2.0)
+	assert.Equal(t, 0.0, sample(regs[1], "svs_publication_fetch_failures_total"))
+	assert.GreaterOrEqual(t, sample(regs[0], "svs_data_served_total"), 2.0)
+}
+
+// Gauges of syncs sharing a Registry add up, and the Cores of HealthSyncs keep to their own series.
+func TestSharedMetrics(t *testing.T) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	cs := svs.GetDefaultConstants()
+	cs.SyncInterval = 200 * time.Millisecond
+	cs.SuppressionInterval = 20 * time.Millisecond
+	cs.HeartbeatRate = 50 * time.Millisecond
+	cs.TrackRate = 200 * time.Millisecond
+	group, _ := enc.NameFromStr("/svs")
+	reg := metrics.NewRegistry(nil)
+	syncs := make([]svs.HealthSync, 2)
+	for i := range syncs {
+		app, err := simnet.NewEngine(net.NewFace())
+		assert.NoError(t, err)
+		defer app.Shutdown()
+		source, _ := enc.NameFromStr("/node" + strconv.Itoa(i))
+		syncs[i] = svs.NewHealthSync(app, &svs.HealthConfig{Source: source, GroupPrefix: group, Metrics: reg}, cs)
+		assert.NoError(t, syncs[i].Listen(ctx))
+		assert.NoError(t, syncs[i].Activate(ctx, true))
+	}
+
+	// each node counts itself and the other one
+	assert.Eventually(t, func() bool { return sample(reg, "svs_alive_nodes") == 4 }, 5*time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, sample(reg, "svs_health_sync_interests_sent_total"), 1.0)
+	assert.Equal(t, -1.0, sample(reg, "svs_sync_interests_sent_total"))
+
+	for _, s := range syncs {
+		assert.NoError(t, s.Shutdown(ctx))
+	}
+	assert.Equal(t, 0.0, sample(reg, "svs_alive_nodes"))
+}
@@ -0,0 +1,152 @@
+package pubsub_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	pubsub "github.com/justincpresley/ndn-sync/pkg/svs/pubsub"
+	simnet "github.com/justincpresley/ndn-sync/util/simnet"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	dum "github.com/zjkmxy/go-ndn/pkg/engine/dummy"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	sec "github.com/zjkmxy/go-ndn/pkg/security"
+)
+
+func TestTopicDataset(t *testing.T) {
+	publisher, _ := enc.NameFromStr("/node1")
+	topic, _ := enc.NameFromStr("/sensors/temp")
+	dataset := pubsub.TopicDataset(publisher, topic)
+	assert.Equal(t, "/node1/32=topic/sensors/temp", dataset.String())
+	p, tp, ok := pubsub.SplitDataset(dataset)
+	assert.True(t, ok)
+	assert.Equal(t, publisher, p)
+	assert.Equal(t, topic, tp)
+	_, _, ok = pubsub.SplitDataset(publisher)
+	assert.False(t, ok)
+}
+
+func TestPublishPerTopic(t *testing.T) {
+	timer := eng.NewTimer()
+	passAll := func(enc.Name, enc.Wire, ndn.Signature) bool { return true }
+	app := eng.NewEngine(dum.NewDummyFace(), timer, sec.NewSha256IntSigner(timer), passAll)
+	source, _ := enc.NameFromStr("/node1")
+	group, _ := enc.NameFromStr("/svs")
+	config := pubsub.GetBasicConfig(source, group)
+	config.Storage = svs.NewMemoryDB(0)
+	ps, err := pubsub.NewPubSub(app, config, svs.GetDefaultConstants())
+	assert.NoError(t, err)
+
+	temp, _ := enc.NameFromStr("/temp")
+	humidity, _ := enc.NameFromStr("/humidity")
+	seqno, err := ps.Publish(temp, []byte("21"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), seqno)
+	seqno, err = ps.Publish(temp, []byte("22"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), seqno)
+	seqno, err = ps.Publish(humidity, []byte("40"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), seqno)
+	sv := ps.Sync().Core().StateVector()
+	assert.Equal(t, uint64(2), sv.Get(pubsub.TopicDataset(source, temp).String()))
+	assert.Equal(t, uint64(1), sv.Get(pubsub.TopicDataset(source, humidity).String()))
+
+	id := ps.Subscribe(temp, func(pubsub.Publication) {})
+	ps.Unsubscribe(id)
+	assert.NoError(t, ps.Shutdown(context.Background()))
+}
+
+func TestSubscribedTopics(t *testing.T) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	net.SetDelay(time.Millisecond, 2*time.Millisecond)
+	group, _ := enc.NameFromStr("/svs")
+	node1, _ := enc.NameFromStr("/node1")
+	keyName, _ := enc.NameFromStr("/node1/KEY/1")
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	cs := svs.GetDefaultConstants()
+	cs.SyncInterval = 300 * time.Millisecond
+	cs.SuppressionInterval = 20 * time.Millisecond
+	newNode := func(source string, config func(*svs.SharedConfig)) (pubsub.PubSub, svs.Database) {
+		app, err := simnet.NewEngine(net.NewFace())
+		assert.NoError(t, err)
+		t.Cleanup(func() { app.Shutdown() })
+		name, _ := enc.NameFromStr(source)
+		cfg := pubsub.GetBasicConfig(name, group)
+		cfg.Storage = svs.NewMemoryDB(0)
+		config(cfg)
+		ps, err := pubsub.NewPubSub(app, cfg, cs)
+		assert.NoError(t, err)
+		return ps, cfg.Storage
+	}
+	publisher, _ := newNode("/node1", func(config *svs.SharedConfig) {
+		config.DataSigner = svs.NewEddsaSigner(keyName, priv, false)
+	})
+	var invalid atomic.Int32
+	subscriber, storage := newNode("/node2", func(config *svs.SharedConfig) {
+		// Topics are published under the publisher, whose keys the policy expects.
+		validator := svs.NewKeyValidator()
+		assert.NoError(t, validator.AddKey(keyName, pub))
+		config.DataValidator = validator
+		config.TrustPolicy = svs.NewSourceKeyPolicy()
+		config.InvalidCallback = func(enc.Name, uint64, ndn.Data) { invalid.Add(1) }
+	})
+	temp, _ := enc.NameFromStr("/temp")
+	humidity, _ := enc.NameFromStr("/humidity")
+	var (
+		mtx      sync.Mutex
+		received []string
+	)
+	subscriber.Subscribe(temp, func(p pubsub.Publication) {
+		mtx.Lock()
+		received = append(received, fmt.Sprintf("%s%s:%d", p.Publisher, p.Topic, p.Seqno))
+		mtx.Unlock()
+	})
+	for _, ps := range []pubsub.PubSub{publisher, subscriber} {
+		assert.NoError(t, ps.Listen(ctx))
+		assert.NoError(t, ps.Activate(ctx, true))
+	}
+
+	for _, p := range []struct {
+		topic   enc.Name
+		payload string
+	}{{temp, "21"}, {humidity, "40"}, {temp, "22"}} {
+		_, err := publisher.Publish(p.topic, []byte(p.payload))
+		assert.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(received) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+	sv := subscriber.Sync().Core().StateVector()
+	assert.Eventually(t, func() bool {
+		sv.RLock()
+		defer sv.RUnlock()
+		return sv.Get(pubsub.TopicDataset(node1, humidity).String()) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	mtx.Lock()
+	assert.ElementsMatch(t, []string{"/node1/temp:1", "/node1/temp:2"}, received)
+	mtx.Unlock()
+	assert.Zero(t, invalid.Load())
+	// Only the subscribed topic was fetched, and so cached.
+	storage.ForEach(func(key []byte, _ []byte) bool {
+		name, err := enc.NameFromBytes(key)
+		assert.False(t, err == nil && strings.Contains(name.String(), "humidity"), name.String())
+		return true
+	})
+	for _, ps := range []pubsub.PubSub{publisher, subscriber} {
+		assert.NoError(t, ps.Shutdown(ctx))
+	}
+}
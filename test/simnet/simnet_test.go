@@ -0,0 +1,93 @@
+package simnet_test
+
+import (
+	"testing"
+	"time"
+
+	simnet "github.com/justincpresley/ndn-sync/util/simnet"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	sec "github.com/zjkmxy/go-ndn/pkg/security"
+	utl "github.com/zjkmxy/go-ndn/pkg/utils"
+)
+
+func newProducer(t *testing.T, app *eng.Engine, prefix enc.Name) {
+	cfg := &ndn.DataConfig{ContentType: utl.IdPtr(ndn.ContentTypeBlob)}
+	err := app.AttachHandler(prefix, func(interest ndn.Interest, rawInterest enc.Wire, sigCovered enc.Wire, reply ndn.ReplyFunc, deadline time.Time) {
+		wire, _, err := app.Spec().MakeData(interest.Name(), cfg, enc.Wire{[]byte("hello")}, sec.NewSha256Signer())
+		if err == nil {
+			reply(wire)
+		}
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, app.RegisterRoute(prefix))
+}
+
+func fetch(app *eng.Engine, name enc.Name) ndn.InterestResult {
+	cfg := &ndn.InterestConfig{Lifetime: utl.IdPtr(100 * time.Millisecond)}
+	wire, _, finalName, err := app.Spec().MakeInterest(name, cfg, nil, nil)
+	if err != nil {
+		return ndn.InterestResultError
+	}
+	ch := make(chan ndn.InterestResult, 1)
+	err = app.Express(finalName, cfg, wire, func(result ndn.InterestResult, data ndn.Data, rawData, sigCovered enc.Wire, nackReason uint64) {
+		ch <- result
+	})
+	if err != nil {
+		return ndn.InterestResultError
+	}
+	return <-ch
+}
+
+func TestForwarding(t *testing.T) {
+	net := simnet.NewNetwork(1)
+	net.SetDelay(time.Millisecond, time.Millisecond)
+	producer, consumer := net.NewFace(), net.NewFace()
+	papp, err := simnet.NewEngine(producer)
+	assert.NoError(t, err)
+	capp, err := simnet.NewEngine(consumer)
+	assert.NoError(t, err)
+	prefix, _ := enc.NameFromStr("/producer")
+	newProducer(t, papp, prefix)
+
+	name, _ := enc.NameFromStr("/producer/data")
+	other, _ := enc.NameFromStr("/other/data")
+	assert.Equal(t, ndn.InterestResultData, fetch(capp, name))
+	assert.Equal(t, ndn.InterestResultTimeout, fetch(capp, other))
+
+	net.Partition([]*simnet.Face{producer})
+	assert.Equal(t, ndn.InterestResultTimeout, fetch(capp, name))
+	net.Heal()
+	assert.Equal(t, ndn.InterestResultData, fetch(capp, name))
+
+	net.SetLoss(1)
+	assert.Equal(t, ndn.InterestResultTimeout, fetch(capp, name))
+	net.SetLoss(0)
+
+	assert.NoError(t, papp.Shutdown())
+	assert.Equal(t, ndn.InterestResultTimeout, fetch(capp, name))
+	assert.NoError(t, capp.Shutdown())
+}
+
+func TestNackNoRoute(t *testing.T) {
+	net := simnet.NewNetwork(1)
+	producer, consumer := net.NewFace(), net.NewFace()
+	papp, err := simnet.NewEngine(producer)
+	assert.NoError(t, err)
+	capp, err := simnet.NewEngine(consumer)
+	assert.NoError(t, err)
+	prefix, _ := enc.NameFromStr("/producer")
+	newProducer(t, papp, prefix)
+
+	other, _ := enc.NameFromStr("/other/data")
+	assert.Equal(t, ndn.InterestResultTimeout, fetch(capp, other))
+	net.SetNackNoRoute(true)
+	assert.Equal(t, ndn.InterestResultNack, fetch(capp, other))
+	name, _ := enc.NameFromStr("/producer/data")
+	assert.Equal(t, ndn.InterestResultData, fetch(capp, name))
+
+	assert.NoError(t, papp.Shutdown())
+	assert.NoError(t, capp.Shutdown())
+}
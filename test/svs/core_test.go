@@ -1,11 +1,19 @@
 package svs_test
 
 import (
+	"context"
+	"sync"
 	"testing"
+	"time"
 
 	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	simnet "github.com/justincpresley/ndn-sync/util/simnet"
 	assert "github.com/stretchr/testify/assert"
 	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	spec "github.com/zjkmxy/go-ndn/pkg/ndn/spec_2022"
+	sec "github.com/zjkmxy/go-ndn/pkg/security"
 )
 
 func TestCoreInitialState(t *testing.T) {
@@ -16,3 +24,241 @@ func TestCoreInitialState(t *testing.T) {
 	core := svs.NewCore(nil, config, svs.GetDefaultConstants())
 	assert.Equal(t, svs.NewStateVector(), core.StateVector())
 }
+
+func TestCoreLifecycle(t *testing.T) {
+	syncPrefix, _ := enc.NameFromStr("/svs")
+	config := &svs.TwoStateCoreConfig{
+		SyncPrefix: syncPrefix,
+	}
+	core := svs.NewCore(nil, config, svs.GetDefaultConstants())
+	ctx := context.Background()
+	assert.NoError(t, core.Activate(ctx, false))
+	assert.ErrorIs(t, core.Activate(ctx, false), svs.ErrAlreadyActive)
+	assert.NoError(t, core.Shutdown(ctx))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	core = svs.NewCore(nil, config, svs.GetDefaultConstants())
+	assert.ErrorIs(t, core.Activate(cancelled, false), context.Canceled)
+}
+
+func TestCoreUpdateBeforeActivate(t *testing.T) {
+	syncPrefix, _ := enc.NameFromStr("/svs")
+	dataset, _ := enc.NameFromStr("/node1")
+	config := &svs.TwoStateCoreConfig{
+		SyncPrefix: syncPrefix,
+	}
+	core := svs.NewCore(nil, config, svs.GetDefaultConstants())
+	for seqno := uint64(1); seqno <= 10; seqno++ {
+		core.Update(dataset, seqno)
+	}
+	assert.Equal(t, uint64(10), core.StateVector().Get(dataset.String()))
+}
+
+func TestCoreFanout(t *testing.T) {
+	syncPrefix, _ := enc.NameFromStr("/svs")
+	node1, _ := enc.NameFromStr("/node1")
+	node2, _ := enc.NameFromStr("/node2")
+	config := &svs.TwoStateCoreConfig{
+		SyncPrefix: syncPrefix,
+	}
+	core := svs.NewCore(nil, config, svs.GetDefaultConstants())
+	slow, fast, gone := core.Subscribe(), core.Subscribe(), core.Subscribe()
+	core.Unsubscribe(gone)
+	_, ok := <-gone
+	assert.False(t, ok)
+
+	sv := svs.NewStateVector()
+	sv.Set(node1.String(), node1, 3, false)
+	interest, covered := makeSyncInterest(t, sec.NewSha256IntSigner(eng.NewTimer()), sv)
+	core.FeedInterest(interest, nil, covered, nil, time.Now())
+	assert.Equal(t, svs.SyncUpdate{{Dataset: node1, StartSeq: 1, EndSeq: 3}}, <-fast)
+
+	sv.Set(node1.String(), node1, 5, false)
+	sv.Set(node2.String(), node2, 1, false)
+	interest, covered = makeSyncInterest(t, sec.NewSha256IntSigner(eng.NewTimer()), sv)
+	core.FeedInterest(interest, nil, covered, nil, time.Now())
+	assert.ElementsMatch(t, svs.SyncUpdate{{Dataset: node1, StartSeq: 4, EndSeq: 5}, {Dataset: node2, StartSeq: 1, EndSeq: 1}}, <-fast)
+
+	// the slow subscriber gets everything, merged into one range per dataset
+	ranges := make(map[string][2]uint64)
+	for len(ranges) < 2 || ranges[node1.String()][1] < 5 {
+		for _, m := range <-slow {
+			r, ok := ranges[m.Dataset.String()]
+			if !ok {
+				r[0] = m.StartSeq
+			}
+			ranges[m.Dataset.String()] = [2]uint64{min(r[0], m.StartSeq), max(r[1], m.EndSeq)}
+		}
+	}
+	assert.Equal(t, map[string][2]uint64{node1.String(): {1, 5}, node2.String(): {1, 1}}, ranges)
+}
+
+func TestSyncRequiresCallback(t *testing.T) {
+	source, _ := enc.NameFromStr("/node1")
+	group, _ := enc.NameFromStr("/svs")
+	_, err := svs.NewSharedSync(nil, svs.GetBasicSharedConfig(source, group, nil), svs.GetDefaultConstants())
+	assert.ErrorIs(t, err, svs.ErrMissingCallback)
+	_, err = svs.NewNativeSync(nil, svs.GetBasicNativeConfig(source, group, nil), svs.GetDefaultConstants())
+	assert.ErrorIs(t, err, svs.ErrMissingCallback)
+}
+
+func TestCoreRestoredState(t *testing.T) {
+	syncPrefix, _ := enc.NameFromStr("/svs")
+	n1, _ := enc.NameFromStr("/node1")
+	n2, _ := enc.NameFromStr("/node2")
+	initial := svs.NewStateVector()
+	initial.Set("/node1", n1, 5, true)
+	initial.Set("/node2", n2, 7, true)
+
+	core := svs.NewCore(nil, &svs.TwoStateCoreConfig{
+		SyncPrefix:   syncPrefix,
+		InitialState: initial,
+		SelfDatasets: []enc.Name{n1},
+	}, svs.GetDefaultConstants())
+	assert.Equal(t, uint64(5), core.StateVector().Get("/node1"))
+	assert.Equal(t, uint64(7), core.StateVector().Get("/node2"))
+	core.Update(n1, 5)
+	assert.Equal(t, uint64(5), core.StateVector().Get("/node1"))
+	core.Update(n1, 6)
+	assert.Equal(t, uint64(6), core.StateVector().Get("/node1"))
+	core.Update(n2, 8)
+	assert.Equal(t, uint64(7), core.StateVector().Get("/node2"))
+}
+
+func nextEventTypes(t *testing.T, l eventLog, n int) []svs.EventType {
+	ret := make([]svs.EventType, n)
+	for i := range ret {
+		ret[i] = nextEvent(t, l).Type
+	}
+	return ret
+}
+
+func TestTwoStateCoreShaking(t *testing.T) {
+	ctx := context.Background()
+	clock := svs.NewVirtualClock(time.Unix(0, 0).UTC(), 1)
+	cs := svs.GetDefaultConstants()
+	cs.Clock = clock
+	cs.ShakingThreshold = 2
+	cs.ShakingRounds = 2
+	cs.ShakingInterval = 100 * time.Millisecond
+	app, err := simnet.NewEngine(simnet.NewNetwork(1).NewFace())
+	assert.NoError(t, err)
+	defer app.Shutdown()
+	syncPrefix, _ := enc.NameFromStr("/svs")
+	node1, _ := enc.NameFromStr("/node1")
+	node2, _ := enc.NameFromStr("/node2")
+	events := make(eventLog, 100)
+	core := svs.NewCore(app, &svs.TwoStateCoreConfig{SyncPrefix: syncPrefix, EventSink: events}, cs)
+	core.Update(node1, 2)
+	clock.Advance(time.Second)
+	assert.NoError(t, core.Activate(ctx, false))
+	defer core.Shutdown(ctx)
+	clock.BlockUntil(1)
+
+	// vectors that only carry new publications are not inconsistent
+	node3, _ := enc.NameFromStr("/node3")
+	for seqno := uint64(1); seqno <= uint64(cs.ShakingThreshold)+1; seqno++ {
+		sv := svs.NewStateVector()
+		sv.Set(node1.String(), node1, 2, false)
+		sv.Set(node3.String(), node3, seqno, false)
+		interest, covered := makeSyncInterest(t, sec.NewSha256IntSigner(eng.NewTimer()), sv)
+		core.FeedInterest(interest, nil, covered, nil, time.Now())
+		assert.Equal(t, []svs.EventType{svs.InterestReceivedEvent, svs.VectorMergedEvent, svs.InterestSuppressedEvent}, nextEventTypes(t, events, 3))
+	}
+
+	// an outdated vector followed by a conflicting one within ShakingWindow
+	sv := svs.NewStateVector()
+	sv.Set(node1.String(), node1, 1, false)
+	interest, covered := makeSyncInterest(t, sec.NewSha256IntSigner(eng.NewTimer()), sv)
+	core.FeedInterest(interest, nil, covered, nil, time.Now())
+	assert.Equal(t, []svs.EventType{svs.InterestReceivedEvent, svs.VectorMergedEvent, svs.SuppressionEnteredEvent}, nextEventTypes(t, events, 3))
+	sv.Set(node2.String(), node2, 1, false)
+	interest, covered = makeSyncInterest(t, sec.NewSha256IntSigner(eng.NewTimer()), sv)
+	core.FeedInterest(interest, nil, covered, nil, time.Now())
+	assert.Equal(t, []svs.EventType{svs.InterestReceivedEvent, svs.VectorMergedEvent}, nextEventTypes(t, events, 2))
+	assert.Equal(t, svs.Event{Type: svs.ShakingEnteredEvent, Time: clock.Now(), State: "suppression", Reason: "burst of inconsistent vectors"}, nextEvent(t, events))
+
+	// vectors are sent on the shortened interval until ShakingRounds pass quietly
+	for round := 1; round <= int(cs.ShakingRounds); round++ {
+		var next time.Duration
+		assert.Eventually(t, func() bool { next, _ = clock.Next(); return next <= 120*time.Millisecond }, time.Second, time.Millisecond)
+		clock.Advance(next)
+		e := nextEvent(t, events)
+		assert.Equal(t, svs.TimerFiredEvent, e.Type)
+		assert.Equal(t, "shaking", e.State)
+		assert.Equal(t, svs.InterestSentEvent, nextEvent(t, events).Type)
+		clock.BlockUntil(1)
+	}
+	assert.Equal(t, svs.ShakingExitedEvent, nextEvent(t, events).Type)
+	next, _ := clock.Next()
+	assert.GreaterOrEqual(t, next, cs.SyncInterval-time.Duration(float64(cs.SyncInterval)*cs.SyncIntervalJitter))
+}
+
+// Records the names of the Interests an engine sends.
+type recordingFace struct {
+	*simnet.Face
+	mtx  sync.Mutex
+	sent []enc.Name
+}
+
+func (f *recordingFace) Send(pkt enc.Wire) error {
+	if p, _, err := spec.ReadPacket(enc.NewWireReader(pkt)); err == nil && p.Interest != nil {
+		f.mtx.Lock()
+		f.sent = append(f.sent, p.Interest.NameV)
+		f.mtx.Unlock()
+	}
+	return f.Face.Send(pkt)
+}
+
+func (f *recordingFace) count(prefix enc.Name) (n int) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	for _, name := range f.sent {
+		if prefix.IsPrefix(name) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCoreListenTimeout(t *testing.T) {
+	net := simnet.NewNetwork(1)
+	net.SetDelay(100*time.Millisecond, 0)
+	face := &recordingFace{Face: net.NewFace()}
+	timer := eng.NewTimer()
+	passAll := func(enc.Name, enc.Wire, ndn.Signature) bool { return true }
+	app := eng.NewEngine(face, timer, sec.NewSha256IntSigner(timer), passAll)
+	assert.NoError(t, app.Start())
+	defer app.Shutdown()
+	syncPrefix, _ := enc.NameFromStr("/svs")
+	core := svs.NewCore(app, &svs.TwoStateCoreConfig{SyncPrefix: syncPrefix}, networkConstants())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, core.Listen(ctx), context.DeadlineExceeded)
+
+	// The registration that completes late is followed by its withdrawal.
+	rib, _ := enc.NameFromStr("/localhost/nfd/rib")
+	assert.Equal(t, 1, face.count(rib))
+	assert.Eventually(t, func() bool { return face.count(rib) == 2 }, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestCoreShutdownExpired(t *testing.T) {
+	ctx := context.Background()
+	apps, _ := networkEngines(t, simnet.NewNetwork(1), 1)
+	defer apps[0].Shutdown()
+	syncPrefix, _ := enc.NameFromStr("/svs")
+	core := svs.NewCore(apps[0], &svs.TwoStateCoreConfig{SyncPrefix: syncPrefix}, networkConstants())
+	assert.NoError(t, core.Listen(ctx))
+	assert.NoError(t, core.Activate(ctx, false))
+	sub := core.Subscribe()
+
+	// An expired context still cleans everything up.
+	expired, cancel := context.WithCancel(ctx)
+	cancel()
+	core.Shutdown(expired)
+	_, ok := <-sub
+	assert.False(t, ok)
+	assert.NoError(t, core.Listen(ctx))
+	assert.NoError(t, core.Shutdown(ctx))
+}
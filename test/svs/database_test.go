@@ -0,0 +1,94 @@
+package svs_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+)
+
+func testDatabaseBasics(t *testing.T, db svs.Database) {
+	assert.Nil(t, db.Get([]byte("one")))
+	assert.NoError(t, db.Set([]byte("one"), []byte{1}))
+	assert.NoError(t, db.Set([]byte("two"), []byte{2}))
+	assert.Equal(t, []byte{1}, db.Get([]byte("one")))
+	assert.NoError(t, db.Set([]byte("one"), []byte{3}))
+	assert.Equal(t, []byte{3}, db.Get([]byte("one")))
+	assert.NoError(t, db.Remove([]byte("one")))
+	assert.Nil(t, db.Get([]byte("one")))
+	assert.Equal(t, []byte{2}, db.Get([]byte("two")))
+	db.Close()
+}
+
+func TestBoltDB(t *testing.T) {
+	db, err := svs.NewBoltDB(filepath.Join(t.TempDir(), "bolt.db"), []byte("svs-packets"))
+	assert.NoError(t, err)
+	testDatabaseBasics(t, db)
+}
+
+func TestLevelDB(t *testing.T) {
+	db, err := svs.NewLevelDB(filepath.Join(t.TempDir(), "level"))
+	assert.NoError(t, err)
+	testDatabaseBasics(t, db)
+}
+
+func TestMemoryDB(t *testing.T) {
+	testDatabaseBasics(t, svs.NewMemoryDB(0))
+}
+
+func TestMemoryDBEviction(t *testing.T) {
+	db := svs.NewMemoryDB(2)
+	db.Set([]byte("one"), []byte{1})
+	db.Set([]byte("two"), []byte{2})
+	db.Get([]byte("one"))
+	db.Set([]byte("three"), []byte{3})
+	assert.Equal(t, 2, db.Len())
+	assert.Equal(t, []byte{1}, db.Get([]byte("one")))
+	assert.Nil(t, db.Get([]byte("two")))
+	assert.Equal(t, []byte{3}, db.Get([]byte("three")))
+}
+
+func TestNullDB(t *testing.T) {
+	db := svs.NewNullDB()
+	assert.NoError(t, db.Set([]byte("one"), []byte{1}))
+	assert.Nil(t, db.Get([]byte("one")))
+}
+
+func TestMemoryDBKeepsSyncState(t *testing.T) {
+	storage := svs.NewMemoryDB(2)
+	source, _ := enc.NameFromStr("/node1")
+	group, _ := enc.NameFromStr("/svs")
+	newSync := func() svs.NativeSync {
+		config := svs.GetBasicNativeConfig(source, group, func(enc.Name, uint64, ndn.Data) {})
+		config.Storage = storage
+		s, err := svs.NewNativeSync(newTestEngine(), config, svs.GetDefaultConstants())
+		assert.NoError(t, err)
+		return s
+	}
+	s := newSync()
+	app, _ := enc.NameFromStr("/chat/room1")
+	name, _, err := s.PublishDataWithName([]byte("first"))
+	assert.NoError(t, err)
+	_, err = s.PublishDataWithMapping([]byte("second"), app)
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err = s.PublishData([]byte("more"))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, s.Shutdown(context.Background()))
+
+	// Publications were evicted past capacity, the Sync's own entries were not.
+	assert.Nil(t, storage.Get(name.Bytes()))
+	second, _ := enc.NameFromStr("/node1/svs/data/seq=2")
+	assert.Equal(t, app.Bytes(), storage.Get(append([]byte("svs-mapping"), second.Bytes()...)))
+	s = newSync()
+	assert.Equal(t, uint64(7), s.Core().StateVector().Get(source.String()))
+	seqno, err := s.PublishData([]byte("after"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), seqno)
+	assert.NoError(t, s.Shutdown(context.Background()))
+}
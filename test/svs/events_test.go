@@ -0,0 +1,111 @@
+package svs_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	simnet "github.com/justincpresley/ndn-sync/util/simnet"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	sec "github.com/zjkmxy/go-ndn/pkg/security"
+)
+
+type eventLog chan svs.Event
+
+func (l eventLog) Emit(e svs.Event) { l <- e }
+
+func nextEvent(t *testing.T, l eventLog) svs.Event {
+	select {
+	case e := <-l:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event emitted")
+		return svs.Event{}
+	}
+}
+
+func TestTwoStateCoreEvents(t *testing.T) {
+	ctx := context.Background()
+	clock := svs.NewVirtualClock(time.Unix(0, 0).UTC(), 1)
+	cs := svs.GetDefaultConstants()
+	cs.Clock = clock
+	app, err := simnet.NewEngine(simnet.NewNetwork(1).NewFace())
+	assert.NoError(t, err)
+	defer app.Shutdown()
+	syncPrefix, _ := enc.NameFromStr("/svs")
+	node1, _ := enc.NameFromStr("/node1")
+	events := make(eventLog, 100)
+	core := svs.NewCore(app, &svs.TwoStateCoreConfig{SyncPrefix: syncPrefix, EventSink: events}, cs)
+	core.Update(node1, 2)
+	clock.Advance(time.Second)
+	assert.NoError(t, core.Activate(ctx, false))
+	defer core.Shutdown(ctx)
+	clock.BlockUntil(1)
+
+	// an outdated vector starts suppression
+	sv := svs.NewStateVector()
+	sv.Set(node1.String(), node1, 1, false)
+	interest, covered := makeSyncInterest(t, sec.NewSha256IntSigner(eng.NewTimer()), sv)
+	core.FeedInterest(interest, nil, covered, nil, time.Now())
+	e := nextEvent(t, events)
+	assert.Equal(t, svs.Event{Type: svs.InterestReceivedEvent, Time: clock.Now(), State: "steady", Remote: map[string]uint64{"/node1": 1}}, e)
+	e = nextEvent(t, events)
+	assert.Equal(t, svs.VectorMergedEvent, e.Type)
+	assert.Equal(t, map[string]uint64{"/node1": 2}, e.Local)
+	assert.True(t, e.Newer)
+	e = nextEvent(t, events)
+	assert.Equal(t, svs.SuppressionEnteredEvent, e.Type)
+	delay := e.Delay
+	assert.LessOrEqual(t, delay, cs.SuppressionInterval+time.Duration(float64(cs.SuppressionInterval)*cs.SuppressionIntervalJitter))
+	assert.Eventually(t, func() bool { next, _ := clock.Next(); return next == delay }, time.Second, time.Millisecond)
+
+	// an up to date vector heard while suppressing makes the Interest unnecessary
+	sv.Set(node1.String(), node1, 2, false)
+	interest, covered = makeSyncInterest(t, sec.NewSha256IntSigner(eng.NewTimer()), sv)
+	core.FeedInterest(interest, nil, covered, nil, time.Now())
+	assert.Equal(t, "suppression", nextEvent(t, events).State)
+	e = nextEvent(t, events)
+	assert.Equal(t, svs.VectorMergedEvent, e.Type)
+	assert.Equal(t, map[string]uint64{"/node1": 2}, e.Record)
+
+	clock.Advance(delay)
+	assert.Equal(t, svs.Event{Type: svs.TimerFiredEvent, Time: clock.Now(), State: "suppression"}, nextEvent(t, events))
+	e = nextEvent(t, events)
+	assert.Equal(t, svs.InterestSuppressedEvent, e.Type)
+	assert.Equal(t, e.Local, e.Record)
+
+	clock.BlockUntil(1)
+	next, _ := clock.Next()
+	clock.Advance(next)
+	assert.Equal(t, "steady", nextEvent(t, events).State)
+	e = nextEvent(t, events)
+	assert.Equal(t, svs.InterestSentEvent, e.Type)
+	assert.Equal(t, map[string]uint64{"/node1": 2}, e.Local)
+}
+
+func TestJSONEventSink(t *testing.T) {
+	written := []svs.Event{
+		{Type: svs.InterestReceivedEvent, Time: time.Unix(1, 5).UTC(), State: "steady", Remote: map[string]uint64{"/node1": 3}},
+		{Type: svs.VectorMergedEvent, State: "suppression", Local: map[string]uint64{"/node1": 3}, Missing: []svs.EventRange{{Dataset: "/node1", StartSeq: 1, EndSeq: 3}}, Newer: true},
+		{Type: svs.SuppressionEnteredEvent, Delay: 150 * time.Millisecond},
+		{Type: svs.InterestRejectedEvent, Reason: "unverifiable"},
+	}
+	var buf bytes.Buffer
+	sink := svs.NewJSONEventSink(&buf)
+	for _, e := range written {
+		sink.Emit(e)
+	}
+	assert.Equal(t, len(written), strings.Count(buf.String(), "\n"))
+	assert.Contains(t, buf.String(), `"type":"suppression_entered"`)
+	read, err := svs.ReadEvents(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, written, read)
+
+	_, err = svs.ReadEvents(strings.NewReader("{\"type\":\"timer_fired\"}\n{\"type\":\"bogus\"}\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
@@ -0,0 +1,97 @@
+package svs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	simnet "github.com/justincpresley/ndn-sync/util/simnet"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+)
+
+func popSeqnos(p svs.FetchPolicy) []uint64 {
+	var ret []uint64
+	for item, ok := p.Pop(); ok; item, ok = p.Pop() {
+		ret = append(ret, item.Seqno)
+	}
+	return ret
+}
+
+func TestFetchPolicyOrder(t *testing.T) {
+	n1, _ := enc.NameFromStr("/node1")
+	n2, _ := enc.NameFromStr("/node2")
+	push := func(p svs.FetchPolicy) svs.FetchPolicy {
+		p.Push(svs.FetchItem{Source: n1, Seqno: 1})
+		p.Push(svs.FetchItem{Source: n2, Seqno: 2})
+		p.Push(svs.FetchItem{Source: n1, Seqno: 3})
+		return p
+	}
+	assert.Equal(t, []uint64{1, 2, 3}, popSeqnos(push(svs.NewFifoFetchPolicy(0))))
+	assert.Equal(t, []uint64{3, 2, 1}, popSeqnos(push(svs.NewLatestFirstFetchPolicy(0))))
+	prio := func(source enc.Name) int {
+		if source.Equal(n2) {
+			return 1
+		}
+		return 0
+	}
+	assert.Equal(t, []uint64{2, 1, 3}, popSeqnos(push(svs.NewPriorityFetchPolicy(0, prio))))
+}
+
+func TestAimdFetchPolicy(t *testing.T) {
+	p := svs.NewAimdFetchPolicy(nil, 4, 5)
+	assert.Equal(t, 4, p.Window())
+	for i := 0; i < 4; i++ {
+		p.OnData(svs.FetchItem{})
+	}
+	assert.Equal(t, 4, p.Window())
+	p.OnData(svs.FetchItem{})
+	assert.Equal(t, 5, p.Window())
+	for i := 0; i < 20; i++ {
+		p.OnData(svs.FetchItem{})
+	}
+	assert.Equal(t, 5, p.Window())
+	p.OnTimeout(svs.FetchItem{})
+	assert.Equal(t, 2, p.Window())
+	p.OnTimeout(svs.FetchItem{})
+	p.OnTimeout(svs.FetchItem{})
+	assert.Equal(t, 1, p.Window())
+}
+
+// Replaces the first two items with ones of its own making.
+type forgingFetchPolicy struct {
+	svs.FetchPolicy
+}
+
+func (p forgingFetchPolicy) Pop() (svs.FetchItem, bool) {
+	item, ok := p.FetchPolicy.Pop()
+	switch {
+	case ok && item.Seqno == 1:
+		return svs.FetchItem{Source: item.Source, Seqno: item.Seqno}, true
+	case ok && item.Seqno == 2:
+		return svs.FetchItem{}, true
+	}
+	return item, ok
+}
+
+func TestFetchPolicyForgedItems(t *testing.T) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	net.SetDelay(time.Millisecond, 2*time.Millisecond)
+	producer := newMappingSync(t, net, "/producer", nil)
+	r := &received{pubs: make(map[string]string)}
+	consumer := newMappingSync(t, net, "/consumer", func(config *svs.NativeConfig) {
+		config.DataCallback = r.callback
+		config.FetchPolicy = forgingFetchPolicy{svs.NewFifoFetchPolicy(1)}
+	})
+	assert.NoError(t, producer.Activate(ctx, true))
+	assert.NoError(t, consumer.Activate(ctx, true))
+	publishMappings(t, producer, "", "", "")
+
+	// The forged items are dropped and the rest is still fetched.
+	assert.Eventually(t, func() bool { return r.count() == 1 }, 5*time.Second, 20*time.Millisecond)
+	r.mtx.Lock()
+	assert.Equal(t, map[string]string{"/producer:3": "no mapping"}, r.pubs)
+	r.mtx.Unlock()
+}
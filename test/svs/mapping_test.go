@@ -0,0 +1,120 @@
+package svs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	simnet "github.com/justincpresley/ndn-sync/util/simnet"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	sec "github.com/zjkmxy/go-ndn/pkg/security"
+	utl "github.com/zjkmxy/go-ndn/pkg/utils"
+)
+
+func newMappingSync(t *testing.T, net *simnet.Network, source string, config func(*svs.NativeConfig)) svs.NativeSync {
+	app, err := simnet.NewEngine(net.NewFace())
+	assert.NoError(t, err)
+	t.Cleanup(func() { app.Shutdown() })
+	name, _ := enc.NameFromStr(source)
+	group, _ := enc.NameFromStr("/svs")
+	cfg := svs.GetBasicNativeConfig(name, group, func(enc.Name, uint64, ndn.Data) {})
+	cfg.Storage = svs.NewMemoryDB(0)
+	if config != nil {
+		config(cfg)
+	}
+	s, err := svs.NewNativeSync(app, cfg, networkConstants())
+	assert.NoError(t, err)
+	assert.NoError(t, s.Listen(context.Background()))
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s
+}
+
+func publishMappings(t *testing.T, s svs.NativeSync, mappings ...string) {
+	for _, m := range mappings {
+		var err error
+		if m == "" {
+			_, err = s.PublishData([]byte("no mapping"))
+		} else {
+			name, _ := enc.NameFromStr(m)
+			_, err = s.PublishDataWithMapping([]byte(m), name)
+		}
+		assert.NoError(t, err)
+	}
+}
+
+func fetchMapping(s svs.NativeSync, source string, start uint64, end uint64) (map[uint64]enc.Name, error) {
+	type result struct {
+		mapping map[uint64]enc.Name
+		err     error
+	}
+	ch := make(chan result, 1)
+	name, _ := enc.NameFromStr(source)
+	s.FetchMapping(name, start, end, func(mapping map[uint64]enc.Name, err error) { ch <- result{mapping, err} })
+	r := <-ch
+	return r.mapping, r.err
+}
+
+func TestFetchMapping(t *testing.T) {
+	net := simnet.NewNetwork(1)
+	producer := newMappingSync(t, net, "/producer", nil)
+	consumer := newMappingSync(t, net, "/consumer", nil)
+	publishMappings(t, producer, "/chat/room1", "", "/chat/room2")
+
+	mapping, err := fetchMapping(consumer, "/producer", 1, 3)
+	assert.NoError(t, err)
+	room1, _ := enc.NameFromStr("/chat/room1")
+	room2, _ := enc.NameFromStr("/chat/room2")
+	assert.Equal(t, map[uint64]enc.Name{1: room1, 3: room2}, mapping)
+}
+
+func TestFetchMappingMalformed(t *testing.T) {
+	net := simnet.NewNetwork(1)
+	consumer := newMappingSync(t, net, "/consumer", nil)
+	app, err := simnet.NewEngine(net.NewFace())
+	assert.NoError(t, err)
+	t.Cleanup(func() { app.Shutdown() })
+	prefix, _ := enc.NameFromStr("/producer/svs/data")
+	// A mapping entry whose seqno is 3 bytes long.
+	content := []byte{byte(svs.TypeMappingEntry), 7, byte(svs.TypeEntrySeqno), 3, 0, 0, 1, byte(enc.TypeName), 0}
+	err = app.AttachHandler(prefix, func(interest ndn.Interest, rawInterest enc.Wire, sigCovered enc.Wire, reply ndn.ReplyFunc, deadline time.Time) {
+		cfg := &ndn.DataConfig{ContentType: utl.IdPtr(ndn.ContentTypeBlob)}
+		wire, _, err := app.Spec().MakeData(interest.Name(), cfg, enc.Wire{content}, sec.NewSha256Signer())
+		if err == nil {
+			reply(wire)
+		}
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, app.RegisterRoute(prefix))
+
+	assert.NotPanics(t, func() {
+		_, err = fetchMapping(consumer, "/producer", 1, 1)
+	})
+	assert.Error(t, err)
+}
+
+func TestMappingFilter(t *testing.T) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	net.SetDelay(time.Millisecond, 2*time.Millisecond)
+	producer := newMappingSync(t, net, "/producer", nil)
+	r := &received{pubs: make(map[string]string)}
+	room1, _ := enc.NameFromStr("/chat/room1")
+	consumer := newMappingSync(t, net, "/consumer", func(config *svs.NativeConfig) {
+		config.DataCallback = r.callback
+		config.MappingFilter = func(_ enc.Name, _ uint64, mapping enc.Name) bool {
+			return mapping != nil && mapping.Equal(room1)
+		}
+	})
+	assert.NoError(t, producer.Activate(ctx, true))
+	assert.NoError(t, consumer.Activate(ctx, true))
+	publishMappings(t, producer, "/chat/room1", "/chat/room2", "", "/chat/room1")
+
+	assert.Eventually(t, func() bool { return r.count() == 2 }, 5*time.Second, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	r.mtx.Lock()
+	assert.Equal(t, map[string]string{"/producer:1": "/chat/room1", "/producer:4": "/chat/room1"}, r.pubs)
+	r.mtx.Unlock()
+}
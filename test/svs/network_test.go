@@ -0,0 +1,418 @@
+package svs_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	simnet "github.com/justincpresley/ndn-sync/util/simnet"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	spec "github.com/zjkmxy/go-ndn/pkg/ndn/spec_2022"
+)
+
+const networkNodes = 5
+
+func networkConstants() *svs.Constants {
+	cs := svs.GetDefaultConstants()
+	cs.SyncInterval = 300 * time.Millisecond
+	cs.SuppressionInterval = 20 * time.Millisecond
+	cs.DataInterestLifeTime = 200 * time.Millisecond
+	return cs
+}
+
+func networkEngines(t *testing.T, net *simnet.Network, n int) ([]*eng.Engine, []*simnet.Face) {
+	apps := make([]*eng.Engine, n)
+	faces := make([]*simnet.Face, n)
+	for i := range apps {
+		faces[i] = net.NewFace()
+		app, err := simnet.NewEngine(faces[i])
+		assert.NoError(t, err)
+		apps[i] = app
+	}
+	return apps, faces
+}
+
+func nodeName(i int) enc.Name {
+	name, _ := enc.NameFromStr(fmt.Sprintf("/node%d", i))
+	return name
+}
+
+func vectorsConverged(cores []svs.Core, expected map[string]uint64) bool {
+	for _, core := range cores {
+		sv := core.StateVector()
+		sv.RLock()
+		for ds, seqno := range expected {
+			if sv.Get(ds) != seqno {
+				sv.RUnlock()
+				return false
+			}
+		}
+		sv.RUnlock()
+	}
+	return true
+}
+
+func testCoreConvergence(t *testing.T, newConfig func(enc.Name) any) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	net.SetDelay(2*time.Millisecond, 3*time.Millisecond)
+	net.SetLoss(0.1)
+	apps, _ := networkEngines(t, net, networkNodes)
+	syncPrefix, _ := enc.NameFromStr("/svs")
+	cores := make([]svs.Core, networkNodes)
+	for i, app := range apps {
+		cores[i] = svs.NewCore(app, newConfig(syncPrefix), networkConstants())
+		assert.NoError(t, cores[i].Listen(ctx))
+		assert.NoError(t, cores[i].Activate(ctx, true))
+	}
+
+	expected := make(map[string]uint64)
+	for i, core := range cores {
+		for seqno := uint64(1); seqno <= uint64(i+1); seqno++ {
+			core.Update(nodeName(i), seqno)
+		}
+		expected[nodeName(i).String()] = uint64(i + 1)
+	}
+	assert.Eventually(t, func() bool { return vectorsConverged(cores, expected) }, 10*time.Second, 20*time.Millisecond)
+
+	for i, core := range cores {
+		assert.NoError(t, core.Shutdown(ctx))
+		apps[i].Shutdown()
+	}
+}
+
+func TestTwoStateCoreConvergence(t *testing.T) {
+	testCoreConvergence(t, func(prefix enc.Name) any {
+		return &svs.TwoStateCoreConfig{SyncPrefix: prefix}
+	})
+}
+
+func TestOneStateCoreConvergence(t *testing.T) {
+	testCoreConvergence(t, func(prefix enc.Name) any {
+		return &svs.OneStateCoreConfig{SyncPrefix: prefix}
+	})
+}
+
+type received struct {
+	mtx  sync.Mutex
+	pubs map[string]string
+}
+
+func (r *received) callback(source enc.Name, seqno uint64, data ndn.Data) {
+	r.mtx.Lock()
+	r.pubs[fmt.Sprintf("%s:%d", source, seqno)] = string(data.Content().Join())
+	r.mtx.Unlock()
+}
+
+func (r *received) count() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return len(r.pubs)
+}
+
+type testSync interface {
+	Listen(context.Context) error
+	Activate(context.Context, bool) error
+	Shutdown(context.Context) error
+	PublishData([]byte) (uint64, error)
+}
+
+// Half of the nodes publish while partitioned from the others, then the network heals.
+func testSyncPartition(t *testing.T, newSync func(*eng.Engine, enc.Name, *received) (testSync, error)) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	net.SetDelay(time.Millisecond, 2*time.Millisecond)
+	apps, faces := networkEngines(t, net, networkNodes)
+	net.Partition(faces[:networkNodes/2], faces[networkNodes/2:])
+	syncs := make([]testSync, networkNodes)
+	recvs := make([]*received, networkNodes)
+	for i, app := range apps {
+		recvs[i] = &received{pubs: make(map[string]string)}
+		s, err := newSync(app, nodeName(i), recvs[i])
+		assert.NoError(t, err)
+		assert.NoError(t, s.Listen(ctx))
+		assert.NoError(t, s.Activate(ctx, true))
+		syncs[i] = s
+	}
+
+	const published = 3
+	for _, s := range syncs {
+		for j := 0; j < published; j++ {
+			_, err := s.PublishData([]byte(fmt.Sprintf("message %d", j)))
+			assert.NoError(t, err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	for i, r := range recvs {
+		assert.Less(t, r.count(), (networkNodes-1)*published, "node %d", i)
+	}
+
+	net.Heal()
+	for i, r := range recvs {
+		assert.Eventually(t, func() bool { return r.count() == (networkNodes-1)*published }, 10*time.Second, 20*time.Millisecond, "node %d", i)
+	}
+	assert.Equal(t, "message 2", recvs[0].pubs[nodeName(networkNodes-1).String()+":3"])
+
+	for i, s := range syncs {
+		assert.NoError(t, s.Shutdown(ctx))
+		apps[i].Shutdown()
+	}
+}
+
+func TestNativeSyncPartition(t *testing.T) {
+	group, _ := enc.NameFromStr("/svs")
+	testSyncPartition(t, func(app *eng.Engine, source enc.Name, r *received) (testSync, error) {
+		config := svs.GetBasicNativeConfig(source, group, r.callback)
+		config.Storage = svs.NewMemoryDB(0)
+		return svs.NewNativeSync(app, config, networkConstants())
+	})
+}
+
+func TestSharedSyncPartition(t *testing.T) {
+	group, _ := enc.NameFromStr("/svs")
+	testSyncPartition(t, func(app *eng.Engine, source enc.Name, r *received) (testSync, error) {
+		config := svs.GetBasicSharedConfig(source, group, r.callback)
+		config.Storage = svs.NewMemoryDB(0)
+		return svs.NewSharedSync(app, config, networkConstants())
+	})
+}
+
+func TestNativeSyncCallbacksSerialized(t *testing.T) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	net.SetDelay(time.Millisecond, 2*time.Millisecond)
+	apps, _ := networkEngines(t, net, 2)
+	group, _ := enc.NameFromStr("/svs")
+	cs := networkConstants()
+	cs.MaxConcurrentDataInterests = 8
+
+	var (
+		running, overlaps, delivered atomic.Int32
+		consumer                     svs.NativeSync
+	)
+	config := svs.GetBasicNativeConfig(nodeName(1), group, func(source enc.Name, seqno uint64, _ ndn.Data) {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(time.Millisecond)
+		// Calling back into the Sync must not deadlock.
+		consumer.NeedData(source, seqno+1000)
+		running.Add(-1)
+		delivered.Add(1)
+	})
+	config.Storage = svs.NewMemoryDB(0)
+	config.ErrorCallback = func(enc.Name, uint64, svs.FetchError) {}
+	consumer, err := svs.NewNativeSync(apps[1], config, cs)
+	assert.NoError(t, err)
+	producerConfig := svs.GetBasicNativeConfig(nodeName(0), group, func(enc.Name, uint64, ndn.Data) {})
+	producerConfig.Storage = svs.NewMemoryDB(0)
+	producer, err := svs.NewNativeSync(apps[0], producerConfig, cs)
+	assert.NoError(t, err)
+	for _, s := range []svs.NativeSync{producer, consumer} {
+		assert.NoError(t, s.Listen(ctx))
+		assert.NoError(t, s.Activate(ctx, true))
+	}
+
+	const published = 20
+	for i := 0; i < published; i++ {
+		_, err := producer.PublishData([]byte("message"))
+		assert.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool { return delivered.Load() == published }, 10*time.Second, 20*time.Millisecond)
+	assert.Equal(t, int32(0), overlaps.Load())
+
+	for i, s := range []svs.NativeSync{producer, consumer} {
+		assert.NoError(t, s.Shutdown(ctx))
+		apps[i].Shutdown()
+	}
+}
+
+func healthConstants() *svs.Constants {
+	cs := networkConstants()
+	cs.HeartbeatRate = 50 * time.Millisecond
+	cs.TrackRate = 200 * time.Millisecond
+	return cs
+}
+
+// The subscription sees every status change from before the node hears its group.
+func newHealthSync(t *testing.T, net *simnet.Network, i int) (svs.HealthSync, *svs.StatusSubscription) {
+	ctx := context.Background()
+	app, err := simnet.NewEngine(net.NewFace())
+	assert.NoError(t, err)
+	t.Cleanup(func() { app.Shutdown() })
+	group, _ := enc.NameFromStr("/svs")
+	s := svs.NewHealthSync(app, &svs.HealthConfig{Source: nodeName(i), GroupPrefix: group}, healthConstants())
+	sub := s.Tracker().Subscribe(64, svs.DropOldest)
+	assert.NoError(t, s.Listen(ctx))
+	assert.NoError(t, s.Activate(ctx, true))
+	return s, sub
+}
+
+// Nodes joining after a departure see it, unless the node came back in between.
+func TestHealthSyncLeave(t *testing.T) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	net.SetDelay(time.Millisecond, 2*time.Millisecond)
+	leaving := nodeName(0).String()
+	first, _ := newHealthSync(t, net, 0)
+	peer, _ := newHealthSync(t, net, 1)
+	t.Cleanup(func() { peer.Shutdown(ctx) })
+	assert.Eventually(t, func() bool { return peer.Tracker().Status(leaving) == svs.Renewed }, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, first.Shutdown(ctx))
+	assert.Eventually(t, func() bool { return peer.Tracker().Status(leaving) == svs.Left }, 5*time.Second, 10*time.Millisecond)
+	late, _ := newHealthSync(t, net, 3)
+	assert.Eventually(t, func() bool { return late.Tracker().Status(leaving) == svs.Left }, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, late.Shutdown(ctx))
+
+	back, _ := newHealthSync(t, net, 0)
+	assert.Eventually(t, func() bool { return peer.Tracker().Status(leaving) == svs.Renewed }, 5*time.Second, 10*time.Millisecond)
+
+	joining, sub := newHealthSync(t, net, 2)
+	t.Cleanup(func() { joining.Shutdown(ctx) })
+	assert.Eventually(t, func() bool { return joining.Tracker().Status(leaving) == svs.Renewed }, 5*time.Second, 10*time.Millisecond)
+	for _, change := range drainStatus(sub.C) {
+		assert.False(t, change.Node == leaving && change.NewStatus == svs.Left)
+	}
+
+	// Leaving again after the restart reaches the group too.
+	assert.NoError(t, back.Shutdown(ctx))
+	assert.Eventually(t, func() bool { return peer.Tracker().Status(leaving) == svs.Left }, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return joining.Tracker().Status(leaving) == svs.Left }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestNativeSyncFetchErrors(t *testing.T) {
+	net := simnet.NewNetwork(1)
+	net.SetNackNoRoute(true)
+	keyName, _ := enc.NameFromStr("/producer/KEY/1")
+	producer := newMappingSync(t, net, "/producer", func(config *svs.NativeConfig) {
+		config.DataSigner = svs.NewHmacSigner(keyName, []byte("wrong"), false)
+	})
+	errs := make(chan svs.FetchError, 4)
+	invalid := make(chan uint64, 4)
+	consumer := newMappingSync(t, net, "/consumer", func(config *svs.NativeConfig) {
+		config.DataValidator = svs.NewHmacValidator([]byte("secret"))
+		config.InvalidCallback = func(_ enc.Name, seqno uint64, _ ndn.Data) { invalid <- seqno }
+		config.ErrorCallback = func(_ enc.Name, _ uint64, err svs.FetchError) { errs <- err }
+	})
+	publishMappings(t, producer, "")
+	source, _ := enc.NameFromStr("/producer")
+	nobody, _ := enc.NameFromStr("/nobody")
+	next := func() svs.FetchError {
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("no fetch error reported")
+			return svs.FetchError{}
+		}
+	}
+
+	consumer.NeedData(source, 1)
+	select {
+	case seqno := <-invalid:
+		assert.Equal(t, uint64(1), seqno)
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalid data not reported")
+	}
+
+	// A Nack fails the fetch at once.
+	consumer.NeedData(nobody, 1)
+	assert.Equal(t, svs.FetchError{Result: ndn.InterestResultNack, NackReason: spec.NackReasonNoRoute}, next())
+
+	// A timeout is retried before it is reported.
+	net.SetLoss(1)
+	consumer.NeedData(source, 1)
+	assert.Equal(t, svs.FetchError{Result: ndn.InterestResultTimeout, Retries: networkConstants().DataInterestRetries}, next())
+	assert.Empty(t, invalid)
+	assert.Empty(t, errs)
+}
+
+// Holds every fetch back, as if the Sync stopped before getting to them.
+type heldFetchPolicy struct{}
+
+func (heldFetchPolicy) Push(svs.FetchItem)         {}
+func (heldFetchPolicy) Pop() (svs.FetchItem, bool) { return svs.FetchItem{}, false }
+func (heldFetchPolicy) Window() int                { return 1 }
+func (heldFetchPolicy) OnData(svs.FetchItem)       {}
+func (heldFetchPolicy) OnTimeout(svs.FetchItem)    {}
+
+func TestNativeSyncRestart(t *testing.T) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	net.SetDelay(time.Millisecond, 2*time.Millisecond)
+	apps, _ := networkEngines(t, net, 3)
+	group, _ := enc.NameFromStr("/svs")
+	storage := svs.NewMemoryDB(0)
+	r := &received{pubs: make(map[string]string)}
+	newConsumer := func(app *eng.Engine, policy svs.FetchPolicy) svs.NativeSync {
+		config := svs.GetBasicNativeConfig(nodeName(1), group, r.callback)
+		config.Storage = storage
+		config.FetchPolicy = policy
+		s, err := svs.NewNativeSync(app, config, networkConstants())
+		assert.NoError(t, err)
+		assert.NoError(t, s.Listen(ctx))
+		assert.NoError(t, s.Activate(ctx, true))
+		return s
+	}
+	producerConfig := svs.GetBasicNativeConfig(nodeName(0), group, func(enc.Name, uint64, ndn.Data) {})
+	producerConfig.Storage = svs.NewMemoryDB(0)
+	producer, err := svs.NewNativeSync(apps[0], producerConfig, networkConstants())
+	assert.NoError(t, err)
+	assert.NoError(t, producer.Listen(ctx))
+	assert.NoError(t, producer.Activate(ctx, true))
+
+	consumer := newConsumer(apps[1], heldFetchPolicy{})
+	_, err = consumer.PublishData([]byte("before"))
+	assert.NoError(t, err)
+	for _, msg := range []string{"one", "two"} {
+		_, err = producer.PublishData([]byte(msg))
+		assert.NoError(t, err)
+	}
+	sv := consumer.Core().StateVector()
+	assert.Eventually(t, func() bool {
+		sv.RLock()
+		defer sv.RUnlock()
+		return sv.Get(nodeName(0).String()) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.NoError(t, consumer.Shutdown(ctx))
+	apps[1].Shutdown()
+	assert.Zero(t, r.count())
+
+	// The publications still pending are fetched after the restart, and the own seqno carries on.
+	consumer = newConsumer(apps[2], nil)
+	assert.Eventually(t, func() bool { return r.count() == 2 }, 5*time.Second, 20*time.Millisecond)
+	seqno, err := consumer.PublishData([]byte("after"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), seqno)
+
+	assert.NoError(t, consumer.Shutdown(ctx))
+	assert.NoError(t, producer.Shutdown(ctx))
+	apps[0].Shutdown()
+	apps[2].Shutdown()
+}
+
+func TestNativeSyncListenCoreFailure(t *testing.T) {
+	ctx := context.Background()
+	apps, _ := networkEngines(t, simnet.NewNetwork(1), 1)
+	defer apps[0].Shutdown()
+	group, _ := enc.NameFromStr("/svs")
+	config := svs.GetBasicNativeConfig(nodeName(0), group, func(enc.Name, uint64, ndn.Data) {})
+	config.Storage = svs.NewMemoryDB(0)
+	s, err := svs.NewNativeSync(apps[0], config, networkConstants())
+	assert.NoError(t, err)
+	assert.NoError(t, s.Core().Listen(ctx))
+
+	// The data prefix is released when the Core fails to listen, so a later Listen succeeds.
+	assert.ErrorIs(t, s.Listen(ctx), svs.ErrAlreadyListening)
+	assert.NoError(t, s.Core().Shutdown(ctx))
+	assert.NoError(t, s.Listen(ctx))
+	assert.NoError(t, s.Shutdown(ctx))
+}
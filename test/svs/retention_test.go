@@ -0,0 +1,143 @@
+package svs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+)
+
+type retained struct {
+	sync    svs.NativeSync
+	storage svs.Database
+	clock   *svs.VirtualClock
+	evicted chan enc.Name
+}
+
+func newRetained(t *testing.T, policy *svs.RetentionPolicy, segmentSize int) *retained {
+	r := &retained{
+		storage: svs.NewMemoryDB(0),
+		clock:   svs.NewVirtualClock(time.Unix(0, 0), 1),
+		evicted: make(chan enc.Name, 16),
+	}
+	source, _ := enc.NameFromStr("/node1")
+	group, _ := enc.NameFromStr("/svs")
+	config := svs.GetBasicNativeConfig(source, group, func(enc.Name, uint64, ndn.Data) {})
+	config.Storage = r.storage
+	config.Retention = policy
+	config.EvictCallback = func(name enc.Name) { r.evicted <- name }
+	constants := svs.GetDefaultConstants()
+	constants.Clock = r.clock
+	if segmentSize > 0 {
+		constants.MaxSegmentSize = segmentSize
+	}
+	var err error
+	r.sync, err = svs.NewNativeSync(newTestEngine(), config, constants)
+	assert.NoError(t, err)
+	return r
+}
+
+func (r *retained) publish(t *testing.T, content string) enc.Name {
+	name, _, err := r.sync.PublishDataWithName([]byte(content))
+	assert.NoError(t, err)
+	return name
+}
+
+// Runs one compaction and returns what it evicted.
+func (r *retained) compact(t *testing.T, interval time.Duration, n int) []enc.Name {
+	r.clock.BlockUntil(1)
+	r.clock.Advance(interval)
+	ret := make([]enc.Name, 0, n)
+	for i := 0; i < n; i++ {
+		select {
+		case name := <-r.evicted:
+			ret = append(ret, name)
+		case <-time.After(time.Second):
+			t.Fatalf("evicted %d of %d publications", i, n)
+		}
+	}
+	r.clock.BlockUntil(1)
+	assert.Empty(t, r.evicted)
+	return ret
+}
+
+func (r *retained) stored(name enc.Name) bool {
+	return r.storage.Get(name.Bytes()) != nil
+}
+
+func TestRetentionMaxCount(t *testing.T) {
+	policy := &svs.RetentionPolicy{MaxPacketsPerSource: 2, CompactInterval: time.Minute}
+	r := newRetained(t, policy, 0)
+	app, _ := enc.NameFromStr("/chat/room1")
+	_, err := r.sync.PublishDataWithMapping([]byte("one"), app)
+	assert.NoError(t, err)
+	first, _ := enc.NameFromStr("/node1/svs/data/seq=1")
+	second := r.publish(t, "two")
+	third := r.publish(t, "three")
+
+	assert.Equal(t, []enc.Name{first}, r.compact(t, time.Minute, 1))
+	assert.False(t, r.stored(first))
+	// The mapping of a publication goes with it.
+	assert.Nil(t, r.storage.Get(append([]byte("svs-mapping"), first.Bytes()...)))
+	assert.True(t, r.stored(second))
+	assert.True(t, r.stored(third))
+	assert.NoError(t, r.sync.Shutdown(context.Background()))
+}
+
+func TestRetentionMaxAge(t *testing.T) {
+	policy := &svs.RetentionPolicy{MaxAge: 90 * time.Second, CompactInterval: time.Minute}
+	r := newRetained(t, policy, 0)
+	old := r.publish(t, "old")
+	r.compact(t, time.Minute, 0)
+	recent := r.publish(t, "recent")
+
+	assert.Equal(t, []enc.Name{old}, r.compact(t, time.Minute, 1))
+	assert.False(t, r.stored(old))
+	assert.True(t, r.stored(recent))
+	assert.NoError(t, r.sync.Shutdown(context.Background()))
+}
+
+func TestRetentionMaxBytes(t *testing.T) {
+	// Measures the stored size of the publications without retention first.
+	sizes := newRetained(t, nil, 10)
+	size := func(name enc.Name) (n int) {
+		sizes.storage.ForEach(func(key []byte, val []byte) bool {
+			k, err := enc.NameFromBytes(key)
+			if err == nil && len(k) >= len(name) && k[:len(name)].Equal(name) {
+				n += len(val)
+			}
+			return true
+		})
+		return n
+	}
+	big := size(sizes.publish(t, "a publication in several segments"))
+	small := size(sizes.publish(t, "one"))
+	assert.NoError(t, sizes.sync.Shutdown(context.Background()))
+
+	policy := &svs.RetentionPolicy{MaxBytes: big + small - 1, CompactInterval: time.Minute}
+	r := newRetained(t, policy, 10)
+	first := r.publish(t, "a publication in several segments")
+	second := r.publish(t, "one")
+
+	// Every segment of the oldest publication goes at once, and it is reported once.
+	assert.Equal(t, []enc.Name{first}, r.compact(t, time.Minute, 1))
+	r.storage.ForEach(func(key []byte, val []byte) bool {
+		k, err := enc.NameFromBytes(key)
+		if err == nil {
+			assert.False(t, len(k) > len(first) && k[:len(first)].Equal(first), k.String())
+		}
+		return true
+	})
+	assert.True(t, r.stored(second))
+	assert.NoError(t, r.sync.Shutdown(context.Background()))
+}
+
+func TestRetentionShutdownTwice(t *testing.T) {
+	r := newRetained(t, svs.GetDefaultRetentionPolicy(), 0)
+	assert.NoError(t, r.sync.Shutdown(context.Background()))
+	assert.NotPanics(t, func() { r.sync.Shutdown(context.Background()) })
+}
@@ -0,0 +1,68 @@
+package svs_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func TestVirtualClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := svs.NewVirtualClock(start, 7)
+	late := clock.NewTimer(20 * time.Millisecond)
+	early := clock.NewTimer(10 * time.Millisecond)
+	stopped := clock.NewTimer(5 * time.Millisecond)
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	next, ok := clock.Next()
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Millisecond, next)
+	clock.Advance(15 * time.Millisecond)
+	assert.Equal(t, start.Add(10*time.Millisecond), <-early.C())
+	assert.Empty(t, late.C())
+	assert.Empty(t, stopped.C())
+	assert.Equal(t, start.Add(15*time.Millisecond), clock.Now())
+
+	assert.True(t, late.Reset(time.Millisecond))
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, start.Add(16*time.Millisecond), <-late.C())
+	_, ok = clock.Next()
+	assert.False(t, ok)
+
+	other := svs.NewVirtualClock(start, 7)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, clock.Rand(time.Second), other.Rand(time.Second))
+	}
+}
+
+func TestSchedulerVirtualClock(t *testing.T) {
+	clock := svs.NewVirtualClock(time.Unix(0, 0), 1)
+	var runs atomic.Int32
+	fired := make(chan struct{}, 10)
+	s := svs.NewScheduler(func() { runs.Add(1); fired <- struct{}{} }, clock)
+	s.ApplyBounds(100*time.Millisecond, 200*time.Millisecond)
+	s.Start(false)
+	clock.BlockUntil(1)
+
+	left := s.TimeLeft()
+	assert.GreaterOrEqual(t, left, 100*time.Millisecond)
+	assert.LessOrEqual(t, left, 200*time.Millisecond)
+	clock.Advance(left - time.Millisecond)
+	assert.Equal(t, int32(0), runs.Load())
+	assert.Equal(t, time.Millisecond, s.TimeLeft())
+	clock.Advance(time.Millisecond)
+	<-fired
+	clock.BlockUntil(1)
+	assert.Equal(t, int32(1), runs.Load())
+
+	s.Set(50 * time.Millisecond)
+	assert.Eventually(t, func() bool { return s.TimeLeft() == 50*time.Millisecond }, time.Second, time.Millisecond)
+	clock.Advance(50 * time.Millisecond)
+	<-fired
+	s.Stop()
+	assert.Equal(t, int32(2), runs.Load())
+}
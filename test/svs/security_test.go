@@ -0,0 +1,109 @@
+package svs_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	spec "github.com/zjkmxy/go-ndn/pkg/ndn/spec_2022"
+	utl "github.com/zjkmxy/go-ndn/pkg/utils"
+)
+
+func makeSyncInterest(t *testing.T, signer ndn.Signer, sv *svs.StateVector) (ndn.Interest, enc.Wire) {
+	name, _ := enc.NameFromStr("/svs/sync")
+	cfg := &ndn.InterestConfig{MustBeFresh: true, CanBePrefix: true, Lifetime: utl.IdPtr(time.Second)}
+	wire, _, _, err := spec.Spec{}.MakeInterest(name, cfg, sv.Encode(false), signer)
+	assert.NoError(t, err)
+	interest, sigCovered, err := spec.Spec{}.ReadInterest(enc.NewWireReader(wire))
+	assert.NoError(t, err)
+	return interest, sigCovered
+}
+
+func TestHmacSignValidate(t *testing.T) {
+	keyName, _ := enc.NameFromStr("/group/KEY/1")
+	interest, covered := makeSyncInterest(t, svs.NewHmacSigner(keyName, []byte("secret"), true), svs.NewStateVector())
+	assert.True(t, svs.NewHmacValidator([]byte("secret")).Validate(interest.Name(), covered, interest.Signature()))
+	assert.False(t, svs.NewHmacValidator([]byte("wrong")).Validate(interest.Name(), covered, interest.Signature()))
+	assert.Equal(t, keyName, interest.Signature().KeyName())
+}
+
+func TestEddsaKeyValidator(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	keyName, _ := enc.NameFromStr("/node1/KEY/1")
+	interest, covered := makeSyncInterest(t, svs.NewEddsaSigner(keyName, priv, true), svs.NewStateVector())
+	v := svs.NewKeyValidator()
+	assert.False(t, v.Validate(interest.Name(), covered, interest.Signature()))
+	assert.NoError(t, v.AddKey(keyName, otherPub))
+	assert.False(t, v.Validate(interest.Name(), covered, interest.Signature()))
+	assert.NoError(t, v.AddKey(keyName, pub))
+	assert.True(t, v.Validate(interest.Name(), covered, interest.Signature()))
+	assert.ErrorIs(t, v.AddKey(keyName, "key"), svs.ErrUnsupportedKey)
+}
+
+func TestReplayValidator(t *testing.T) {
+	keyName, _ := enc.NameFromStr("/group/KEY/1")
+	signer := svs.NewHmacSigner(keyName, []byte("secret"), true)
+	v := svs.NewReplayValidator(svs.NewHmacValidator([]byte("secret")), time.Minute, svs.NewSystemClock())
+	interest, covered := makeSyncInterest(t, signer, svs.NewStateVector())
+	assert.True(t, v.Validate(interest.Name(), covered, interest.Signature()))
+	assert.False(t, v.Validate(interest.Name(), covered, interest.Signature()))
+
+	next, nextCovered := makeSyncInterest(t, signer, svs.NewStateVector())
+	assert.True(t, v.Validate(next.Name(), nextCovered, next.Signature()))
+	assert.False(t, v.Validate(interest.Name(), covered, interest.Signature()))
+
+	// a restarted signer starts over from seqno 1 at a later time
+	time.Sleep(2 * time.Millisecond)
+	restarted, restartedCovered := makeSyncInterest(t, svs.NewHmacSigner(keyName, []byte("secret"), true), svs.NewStateVector())
+	assert.True(t, v.Validate(restarted.Name(), restartedCovered, restarted.Signature()))
+
+	forged, forgedCovered := makeSyncInterest(t, svs.NewHmacSigner(keyName, []byte("forged"), true), svs.NewStateVector())
+	assert.False(t, v.Validate(forged.Name(), forgedCovered, forged.Signature()))
+	unsigned, unsignedCovered := makeSyncInterest(t, svs.NewHmacSigner(keyName, []byte("secret"), false), svs.NewStateVector())
+	assert.False(t, v.Validate(unsigned.Name(), unsignedCovered, unsigned.Signature()))
+
+	later := svs.NewVirtualClock(time.Now().Add(time.Hour), 1)
+	stale := svs.NewReplayValidator(svs.NewHmacValidator([]byte("secret")), time.Minute, later)
+	fresh, freshCovered := makeSyncInterest(t, signer, svs.NewStateVector())
+	assert.False(t, stale.Validate(fresh.Name(), freshCovered, fresh.Signature()))
+}
+
+func TestCoreRejectsForgedVector(t *testing.T) {
+	syncPrefix, _ := enc.NameFromStr("/svs")
+	config := &svs.TwoStateCoreConfig{
+		SyncPrefix: syncPrefix,
+		Validator:  svs.NewHmacValidator([]byte("secret")),
+	}
+	core := svs.NewCore(nil, config, svs.GetDefaultConstants())
+	sv := svs.NewStateVector()
+	n, _ := enc.NameFromStr("/node1")
+	sv.Set("/node1", n, 5, false)
+	keyName, _ := enc.NameFromStr("/group/KEY/1")
+
+	interest, covered := makeSyncInterest(t, svs.NewHmacSigner(keyName, []byte("forged"), true), sv)
+	core.FeedInterest(interest, nil, covered, nil, time.Now())
+	assert.Equal(t, uint64(0), core.StateVector().Get("/node1"))
+
+	interest, covered = makeSyncInterest(t, svs.NewHmacSigner(keyName, []byte("secret"), true), sv)
+	core.FeedInterest(interest, nil, covered, nil, time.Now())
+	assert.Equal(t, uint64(5), core.StateVector().Get("/node1"))
+}
+
+func TestSourceKeyPolicy(t *testing.T) {
+	policy := svs.NewSourceKeyPolicy()
+	source, _ := enc.NameFromStr("/node1")
+	dataName, _ := enc.NameFromStr("/group/data/node1/seq=1")
+	goodKey, _ := enc.NameFromStr("/node1/KEY/abc")
+	badKey, _ := enc.NameFromStr("/node2/KEY/abc")
+	shortKey, _ := enc.NameFromStr("/node1")
+	assert.True(t, policy.Permits(source, dataName, goodKey))
+	assert.False(t, policy.Permits(source, dataName, badKey))
+	assert.False(t, policy.Permits(source, dataName, shortKey))
+	assert.False(t, policy.Permits(source, dataName, nil))
+}
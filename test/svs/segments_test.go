@@ -0,0 +1,126 @@
+package svs_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	simnet "github.com/justincpresley/ndn-sync/util/simnet"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	sec "github.com/zjkmxy/go-ndn/pkg/security"
+	utl "github.com/zjkmxy/go-ndn/pkg/utils"
+)
+
+type fetched struct {
+	data    chan []byte
+	invalid chan enc.Name
+}
+
+func newSegmentConsumer(t *testing.T, net *simnet.Network, cs *svs.Constants) (svs.NativeSync, *fetched) {
+	app, err := simnet.NewEngine(net.NewFace())
+	assert.NoError(t, err)
+	t.Cleanup(func() { app.Shutdown() })
+	f := &fetched{data: make(chan []byte, 10), invalid: make(chan enc.Name, 10)}
+	source, _ := enc.NameFromStr("/consumer")
+	group, _ := enc.NameFromStr("/svs")
+	config := svs.GetBasicNativeConfig(source, group, func(_ enc.Name, _ uint64, data ndn.Data) {
+		f.data <- data.Content().Join()
+	})
+	config.Storage = svs.NewMemoryDB(0)
+	config.InvalidCallback = func(_ enc.Name, _ uint64, data ndn.Data) { f.invalid <- data.Name() }
+	s, err := svs.NewNativeSync(app, config, cs)
+	assert.NoError(t, err)
+	return s, f
+}
+
+// Serves hand-made segments of /producer/svs/data/seq=1, answering the prefix Interest with first.
+func newSegmentProducer(t *testing.T, net *simnet.Network, first uint64, segments map[uint64]string, finals map[uint64]uint64) {
+	app, err := simnet.NewEngine(net.NewFace())
+	assert.NoError(t, err)
+	t.Cleanup(func() { app.Shutdown() })
+	prefix, _ := enc.NameFromStr("/producer/svs/data")
+	base := append(prefix, enc.NewSequenceNumComponent(1))
+	err = app.AttachHandler(prefix, func(interest ndn.Interest, rawInterest enc.Wire, sigCovered enc.Wire, reply ndn.ReplyFunc, deadline time.Time) {
+		seg := first
+		if name := interest.Name(); name[len(name)-1].Typ == enc.TypeSegmentNameComponent {
+			seg = name[len(name)-1].NumberVal()
+		}
+		content, ok := segments[seg]
+		if !ok {
+			return
+		}
+		final := enc.NewSegmentComponent(finals[seg])
+		cfg := &ndn.DataConfig{ContentType: utl.IdPtr(ndn.ContentTypeBlob), FinalBlockID: &final}
+		name := append(append(enc.Name{}, base...), enc.NewSegmentComponent(seg))
+		wire, _, err := app.Spec().MakeData(name, cfg, enc.Wire{[]byte(content)}, sec.NewSha256Signer())
+		if err == nil {
+			reply(wire)
+		}
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, app.RegisterRoute(prefix))
+}
+
+func TestSegmentedPublication(t *testing.T) {
+	ctx := context.Background()
+	net := simnet.NewNetwork(1)
+	cs := svs.GetDefaultConstants()
+	cs.MaxSegmentSize = 10
+	cs.MaxSegments = 5
+	app, err := simnet.NewEngine(net.NewFace())
+	assert.NoError(t, err)
+	defer app.Shutdown()
+	source, _ := enc.NameFromStr("/producer")
+	group, _ := enc.NameFromStr("/svs")
+	config := svs.GetBasicNativeConfig(source, group, func(enc.Name, uint64, ndn.Data) {})
+	config.Storage = svs.NewMemoryDB(0)
+	producer, err := svs.NewNativeSync(app, config, cs)
+	assert.NoError(t, err)
+	assert.NoError(t, producer.Listen(ctx))
+	defer producer.Shutdown(ctx)
+
+	content := bytes.Repeat([]byte("0123456789"), 4)
+	content = append(content, "tail"...)
+	_, err = producer.PublishData(content)
+	assert.NoError(t, err)
+	_, err = producer.PublishData(make([]byte, 51))
+	assert.ErrorIs(t, err, svs.ErrTooManySegments)
+
+	consumer, f := newSegmentConsumer(t, net, cs)
+	consumer.NeedData(source, 1)
+	assert.Equal(t, content, <-f.data)
+}
+
+func TestSegmentsOutOfOrder(t *testing.T) {
+	net := simnet.NewNetwork(1)
+	newSegmentProducer(t, net, 2, map[uint64]string{0: "abc", 1: "def", 2: "ghi"}, map[uint64]uint64{0: 2, 1: 2, 2: 2})
+	consumer, f := newSegmentConsumer(t, net, svs.GetDefaultConstants())
+	source, _ := enc.NameFromStr("/producer")
+	consumer.NeedData(source, 1)
+	assert.Equal(t, []byte("abcdefghi"), <-f.data)
+}
+
+func TestSegmentsRefused(t *testing.T) {
+	source, _ := enc.NameFromStr("/producer")
+	cs := svs.GetDefaultConstants()
+	cs.MaxSegments = 4
+
+	// a FinalBlockId beyond MaxSegments is refused without fetching anything else
+	net := simnet.NewNetwork(1)
+	newSegmentProducer(t, net, 0, map[uint64]string{0: "abc"}, map[uint64]uint64{0: 1 << 40})
+	consumer, f := newSegmentConsumer(t, net, cs)
+	consumer.NeedData(source, 1)
+	assert.Equal(t, enc.NewSegmentComponent(0), (<-f.invalid)[4])
+
+	// segments disagreeing on the FinalBlockId
+	net = simnet.NewNetwork(1)
+	newSegmentProducer(t, net, 0, map[uint64]string{0: "abc", 1: "def"}, map[uint64]uint64{0: 1, 1: 3})
+	consumer, f = newSegmentConsumer(t, net, cs)
+	consumer.NeedData(source, 1)
+	assert.Equal(t, enc.NewSegmentComponent(1), (<-f.invalid)[4])
+	assert.Empty(t, f.data)
+}
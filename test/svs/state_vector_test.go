@@ -1,6 +1,7 @@
 package svs_test
 
 import (
+	"strconv"
 	"testing"
 
 	svs "github.com/justincpresley/ndn-sync/pkg/svs"
@@ -102,6 +103,49 @@ func TestStateVectorInformalDecodeStatic(t *testing.T) {
 	assert.Equal(t, int(2), sv.Len())
 }
 
+func TestStateVectorCompressedEncodeDecode(t *testing.T) {
+	sv := svs.NewStateVector()
+	for _, e := range []struct {
+		name  string
+		seqno uint64
+	}{{"/org/site/one", 40}, {"/org/site/two", 2}, {"/org/other", 1000}, {"/solo", 7}} {
+		n, _ := enc.NameFromStr(e.name)
+		sv.Set(e.name, n, e.seqno, true)
+	}
+	wire := sv.EncodeCompressed()
+	assert.Less(t, len(wire.Join()), len(sv.Encode(false).Join()))
+	nsv, err := svs.ParseStateVector(enc.NewWireReader(wire), true)
+	assert.NoError(t, err)
+	assert.Equal(t, sv, nsv)
+	nsv, err = svs.ParseStateVector(enc.NewWireReader(wire), false)
+	assert.NoError(t, err)
+	assert.Equal(t, sv, nsv)
+}
+
+func TestStateVectorCompressedDecodeStatic(t *testing.T) {
+	wire := enc.Wire{[]byte{205, 17, 0, 8, 8, 3, 111, 114, 103, 8, 1, 97, 10, 1, 3, 8, 1, 98, 3}}
+	sv, err := svs.ParseStateVector(enc.NewWireReader(wire), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "/org/a:5 /org/b:3", sv.String())
+	_, err = svs.ParseStateVector(enc.NewWireReader(enc.Wire{[]byte{205, 4, 1, 0, 0, 2}}), false)
+	assert.Error(t, err)
+}
+
+func TestStateVectorCompressedDecodeMalformed(t *testing.T) {
+	for _, raw := range []string{
+		"\xcd\x11\xff\xe5000000000000000",                  // shared prefix longer than any name
+		"\xcd\x11\x00\xff\xff\xff\xff\xff\xff\xff\xff\xff", // name length beyond the vector
+		"\xcd\xff\xff\xff\xff\xff\xff\xff\xff\xff\x00",     // vector length beyond the packet
+		"\xcd\x05\x00\x08\x08\x01\x61",                     // name length beyond the vector
+		"\xcd\x03\x00\x00",                                 // missing seqno
+	} {
+		assert.NotPanics(t, func() {
+			_, err := svs.ParseStateVector(enc.NewWireReader(enc.Wire{[]byte(raw)}), false)
+			assert.Error(t, err, "%q", raw)
+		})
+	}
+}
+
 func TestStateVectorOrdering(t *testing.T) {
 	sv1 := svs.NewStateVector()
 	n, _ := enc.NameFromStr("/one")
@@ -120,3 +164,17 @@ func TestStateVectorOrdering(t *testing.T) {
 	}
 	assert.Equal(t, sv1, sv2)
 }
+
+func TestStateVectorPartial(t *testing.T) {
+	sv := svs.NewStateVector()
+	for i := 1; i <= 5; i++ {
+		n, _ := enc.NameFromStr("/node" + strconv.Itoa(i))
+		sv.Set(n.String(), n, uint64(i), false)
+	}
+	assert.Equal(t, "/node5:5 /node4:4 /node3:3 /node2:2 /node1:1", sv.String())
+	assert.Equal(t, "/node5:5 /node4:4", sv.Partial(2, 0, 0).String())
+	assert.Equal(t, "/node5:5 /node4:4 /node3:3 /node2:2", sv.Partial(2, 2, 0).String())
+	assert.Equal(t, "/node5:5 /node4:4 /node1:1 /node3:3", sv.Partial(2, 2, 2).String())
+	assert.Equal(t, "/node5:5 /node4:4 /node2:2 /node1:1 /node3:3", sv.Partial(2, 10, 1).String())
+	assert.Equal(t, sv.String(), sv.Partial(10, 10, 0).String())
+}
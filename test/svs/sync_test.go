@@ -0,0 +1,90 @@
+package svs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	assert "github.com/stretchr/testify/assert"
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	dum "github.com/zjkmxy/go-ndn/pkg/engine/dummy"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	spec "github.com/zjkmxy/go-ndn/pkg/ndn/spec_2022"
+	sec "github.com/zjkmxy/go-ndn/pkg/security"
+	utl "github.com/zjkmxy/go-ndn/pkg/utils"
+)
+
+func newTestEngine() *eng.Engine {
+	timer := eng.NewTimer()
+	passAll := func(enc.Name, enc.Wire, ndn.Signature) bool { return true }
+	return eng.NewEngine(dum.NewDummyFace(), timer, sec.NewSha256IntSigner(timer), passAll)
+}
+
+func TestPublishDataWithName(t *testing.T) {
+	source, _ := enc.NameFromStr("/node1")
+	group, _ := enc.NameFromStr("/svs")
+	storage := svs.NewMemoryDB(0)
+	config := svs.GetBasicNativeConfig(source, group, func(enc.Name, uint64, ndn.Data) {})
+	config.Storage = storage
+	sync, err := svs.NewNativeSync(newTestEngine(), config, svs.GetDefaultConstants())
+	assert.NoError(t, err)
+
+	name, seqno, err := sync.PublishDataWithName([]byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), seqno)
+	assert.NotNil(t, storage.Get(name.Bytes()))
+	assert.Equal(t, uint64(1), sync.Core().StateVector().Get(source.String()))
+
+	seqno, err = sync.PublishData([]byte("world"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), seqno)
+}
+
+func TestPublishDataToForeignDataset(t *testing.T) {
+	source, _ := enc.NameFromStr("/node1")
+	other, _ := enc.NameFromStr("/node2/topic")
+	group, _ := enc.NameFromStr("/svs")
+	config := svs.GetBasicSharedConfig(source, group, func(enc.Name, uint64, ndn.Data) {})
+	config.Storage = svs.NewMemoryDB(0)
+	sync, err := svs.NewSharedSync(newTestEngine(), config, svs.GetDefaultConstants())
+	assert.NoError(t, err)
+	_, _, err = sync.PublishDataTo(other, []byte("hello"))
+	assert.ErrorIs(t, err, svs.ErrForeignDataset)
+}
+
+func TestServeMapping(t *testing.T) {
+	source, _ := enc.NameFromStr("/node1")
+	group, _ := enc.NameFromStr("/svs")
+	config := svs.GetBasicNativeConfig(source, group, func(enc.Name, uint64, ndn.Data) {})
+	config.Storage = svs.NewMemoryDB(0)
+	sync, err := svs.NewNativeSync(newTestEngine(), config, svs.GetDefaultConstants())
+	assert.NoError(t, err)
+	app, _ := enc.NameFromStr("/chat/room1")
+	_, err = sync.PublishDataWithMapping([]byte("hello"), app)
+	assert.NoError(t, err)
+	_, err = sync.PublishData([]byte("world"))
+	assert.NoError(t, err)
+
+	name, _ := enc.NameFromStr("/node1/svs/data/mapping/seq=1/seq=2")
+	wire, _, _, err := spec.Spec{}.MakeInterest(name, &ndn.InterestConfig{Lifetime: utl.IdPtr(time.Second)}, nil, nil)
+	assert.NoError(t, err)
+	interest, _, err := spec.Spec{}.ReadInterest(enc.NewWireReader(wire))
+	assert.NoError(t, err)
+	var replied enc.Wire
+	sync.FeedInterest(interest, wire, nil, func(w enc.Wire) error { replied = w; return nil }, time.Now())
+	data, _, err := spec.Spec{}.ReadData(enc.NewWireReader(replied))
+	assert.NoError(t, err)
+	assert.Equal(t, name, data.Name())
+	assert.Contains(t, string(data.Content().Join()), string(app.Bytes()))
+}
+
+func TestHealthPublishStatus(t *testing.T) {
+	source, _ := enc.NameFromStr("/node1")
+	group, _ := enc.NameFromStr("/svs")
+	sync := svs.NewHealthSync(newTestEngine(), &svs.HealthConfig{Source: source, GroupPrefix: group}, svs.GetDefaultConstants())
+	sync.PublishStatus([]byte("role=leader"))
+	assert.Equal(t, []byte("role=leader"), sync.Tracker().Payload(source.String()))
+	assert.NoError(t, sync.Shutdown(context.Background()))
+}
@@ -0,0 +1,183 @@
+package svs_test
+
+import (
+	"testing"
+	"time"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func TestTrackerLeave(t *testing.T) {
+	cs := svs.GetDefaultConstants()
+	cs.HeartbeatsToRenew = 1
+	cs.InitialStatusChangeChannelSize = 10
+	tracker := svs.NewTracker("/node1", cs)
+	recv := tracker.Chan()
+	assert.Equal(t, svs.StatusChange{Node: "/node1", OldStatus: svs.Unseen, NewStatus: svs.Expired}, <-recv)
+
+	tracker.Leave("/node2")
+	assert.Equal(t, svs.Unseen, tracker.Status("/node2"))
+
+	tracker.Reset("/node2")
+	tracker.Reset("/node2")
+	assert.Equal(t, svs.StatusChange{Node: "/node2", OldStatus: svs.Unseen, NewStatus: svs.Expired}, <-recv)
+	assert.Equal(t, svs.StatusChange{Node: "/node2", OldStatus: svs.Expired, NewStatus: svs.Renewed}, <-recv)
+
+	tracker.Leave("/node2")
+	tracker.Leave("/node2")
+	assert.Equal(t, svs.StatusChange{Node: "/node2", OldStatus: svs.Renewed, NewStatus: svs.Left}, <-recv)
+	assert.Equal(t, svs.Left, tracker.Status("/node2"))
+
+	tracker.Reset("/node2")
+	assert.Equal(t, svs.StatusChange{Node: "/node2", OldStatus: svs.Left, NewStatus: svs.Renewed}, <-recv)
+	assert.Empty(t, recv)
+}
+
+func TestTrackerMembers(t *testing.T) {
+	cs := svs.GetDefaultConstants()
+	cs.HeartbeatsToRenew = 2
+	cs.InitialStatusChangeChannelSize = 10
+	tracker := svs.NewTracker("/node1", cs)
+	tracker.Reset("/node3")
+	tracker.Reset("/node2")
+	tracker.Reset("/node2")
+	tracker.Reset("/node2")
+
+	members := tracker.Members()
+	assert.Len(t, members, 3)
+	assert.Equal(t, []string{"/node1", "/node2", "/node3"}, []string{members[0].Node, members[1].Node, members[2].Node})
+	assert.Equal(t, svs.Expired, members[0].Status)
+	assert.True(t, members[0].LastBeat.IsZero())
+	assert.Equal(t, svs.Renewed, members[1].Status)
+	assert.False(t, members[1].LastBeat.IsZero())
+	assert.Equal(t, svs.Expired, members[2].Status)
+	assert.False(t, members[2].LastBeat.IsZero())
+	assert.Equal(t, uint(0), members[2].Beats)
+
+	alive := tracker.Alive()
+	assert.Len(t, alive, 1)
+	assert.Equal(t, "/node2", alive[0].Node)
+}
+
+func TestTrackerPayload(t *testing.T) {
+	cs := svs.GetDefaultConstants()
+	cs.InitialStatusChangeChannelSize = 10
+	tracker := svs.NewTracker("/node1", cs)
+	tracker.SetPayload("/node2", 1, []byte("unseen"))
+	assert.Nil(t, tracker.Payload("/node2"))
+
+	tracker.Reset("/node2")
+	tracker.SetPayload("/node2", 2, []byte("load=1"))
+	tracker.SetPayload("/node2", 1, []byte("stale"))
+	assert.Equal(t, []byte("load=1"), tracker.Payload("/node2"))
+	tracker.SetPayload("/node2", 2, []byte("load=2"))
+	assert.Equal(t, []byte("load=2"), tracker.Members()[1].Payload)
+}
+
+func TestTrackerNextExpiry(t *testing.T) {
+	clock := svs.NewVirtualClock(time.Unix(0, 0), 1)
+	cs := svs.GetDefaultConstants()
+	cs.HeartbeatsToRenew = 1
+	cs.HeartbeatsToExpire = 2
+	cs.TrackRate = 10 * time.Millisecond
+	cs.InitialStatusChangeChannelSize = 10
+	cs.Clock = clock
+	tracker := svs.NewTracker("/node1", cs)
+	tracker.Reset("/node1")
+	tracker.Reset("/node1")
+	_, ok := tracker.NextExpiry()
+	assert.False(t, ok)
+
+	tracker.Reset("/node2")
+	tracker.Reset("/node2")
+	assert.Equal(t, svs.Renewed, tracker.Status("/node2"))
+	next, ok := tracker.NextExpiry()
+	assert.True(t, ok)
+	assert.Equal(t, 20*time.Millisecond, next)
+
+	clock.Advance(next - time.Millisecond)
+	tracker.Detect()
+	assert.Equal(t, svs.Renewed, tracker.Status("/node2"))
+	clock.Advance(2 * time.Millisecond)
+	tracker.Detect()
+	assert.Equal(t, svs.Expired, tracker.Status("/node2"))
+	_, ok = tracker.NextExpiry()
+	assert.False(t, ok)
+}
+
+func TestTrackerRenewalSpacing(t *testing.T) {
+	clock := svs.NewVirtualClock(time.Unix(0, 0), 1)
+	cs := svs.GetDefaultConstants()
+	cs.HeartbeatsToRenew = 2
+	cs.TrackRate = 10 * time.Millisecond
+	cs.Clock = clock
+	tracker := svs.NewTracker("/node1", cs)
+	tracker.Reset("/node2")
+	tracker.Reset("/node2")
+	assert.Equal(t, uint(1), tracker.Members()[1].Beats)
+
+	clock.Advance(11 * time.Millisecond)
+	tracker.Reset("/node2")
+	assert.Equal(t, svs.Expired, tracker.Status("/node2"))
+	clock.Advance(9 * time.Millisecond)
+	tracker.Reset("/node2")
+	assert.Equal(t, svs.Renewed, tracker.Status("/node2"))
+	assert.Equal(t, clock.Now(), tracker.Members()[1].LastBeat)
+
+	tracker.Reset("/node1")
+	clock.Advance(time.Second)
+	assert.Equal(t, cs.HeartbeatRate-time.Second, tracker.UntilBeat())
+}
+
+func drainStatus(ch <-chan svs.StatusChange) []svs.StatusChange {
+	var ret []svs.StatusChange
+	for {
+		select {
+		case change, ok := <-ch:
+			if !ok {
+				return ret
+			}
+			ret = append(ret, change)
+		case <-time.After(50 * time.Millisecond):
+			return ret
+		}
+	}
+}
+
+func TestTrackerSubscribeDropOldest(t *testing.T) {
+	tracker := svs.NewTracker("/node1", svs.GetDefaultConstants())
+	sub := tracker.Subscribe(1, svs.DropOldest)
+	for _, node := range []string{"/a", "/b", "/c", "/d", "/e", "/f", "/g", "/h"} {
+		tracker.Reset(node)
+	}
+	changes := drainStatus(sub.C)
+	assert.Equal(t, 8, len(changes)+int(sub.Dropped()))
+	assert.Equal(t, "/h", changes[len(changes)-1].Node)
+
+	tracker.Unsubscribe(sub)
+	_, ok := <-sub.C
+	assert.False(t, ok)
+	tracker.Close()
+}
+
+func TestTrackerSubscribeCoalesce(t *testing.T) {
+	cs := svs.GetDefaultConstants()
+	cs.HeartbeatsToRenew = 1
+	tracker := svs.NewTracker("/node1", cs)
+	sub := tracker.Subscribe(1, svs.Coalesce)
+	tracker.Reset("/node2")
+	tracker.Reset("/node2")
+	tracker.Leave("/node2")
+	tracker.Reset("/node2")
+	tracker.Leave("/node2")
+	changes := drainStatus(sub.C)
+	assert.Equal(t, uint64(0), sub.Dropped())
+	status := svs.Unseen
+	for _, change := range changes {
+		assert.Equal(t, status, change.OldStatus)
+		status = change.NewStatus
+	}
+	assert.Equal(t, svs.Left, status)
+	tracker.Close()
+}
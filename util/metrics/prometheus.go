@@ -0,0 +1,240 @@
+// Package metrics collects the instruments of SVS Cores and Syncs and exposes them in the
+// Prometheus text format, so they can be scraped without depending on a Prometheus client.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	svs "github.com/justincpresley/ndn-sync/pkg/svs"
+)
+
+// Upper bounds of histogram buckets, in seconds, as used by Prometheus clients.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type kind int
+
+const (
+	counterKind kind = iota
+	gaugeKind
+	histogramKind
+)
+
+func (k kind) String() string {
+	return [...]string{"counter", "gauge", "histogram"}[k]
+}
+
+type family struct {
+	name   string
+	help   string
+	kind   kind
+	value  *value
+	histo  *histogram
+	labels string
+}
+
+// Registry implements svs.Metrics. Every series carries the labels given to NewRegistry,
+// which tells apart several nodes exposed by one process.
+type Registry struct {
+	mtx      sync.Mutex
+	families map[string]*family
+	labels   string
+	buckets  []float64
+}
+
+func NewRegistry(labels map[string]string) *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+		labels:   formatLabels(labels),
+		buckets:  DefaultBuckets,
+	}
+}
+
+// Must be called before any histogram is created.
+func (r *Registry) SetBuckets(buckets []float64) {
+	r.buckets = slices.Clone(buckets)
+	slices.Sort(r.buckets)
+}
+
+func (r *Registry) Counter(name string, help string) svs.Counter {
+	return r.family(name, help, counterKind).value
+}
+
+func (r *Registry) Gauge(name string, help string) svs.Gauge {
+	return r.family(name, help, gaugeKind).value
+}
+
+func (r *Registry) Histogram(name string, help string) svs.Histogram {
+	return r.family(name, help, histogramKind).histo
+}
+
+// An instrument asked for again under another kind panics, as its series would be ambiguous.
+func (r *Registry) family(name string, help string, k kind) *family {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if f, ok := r.families[name]; ok {
+		if f.kind != k {
+			panic(fmt.Sprintf("metrics: %s is a %s, not a %s", name, f.kind, k))
+		}
+		return f
+	}
+	f := &family{name: name, help: help, kind: k, labels: r.labels}
+	if k == histogramKind {
+		f.histo = newHistogram(r.buckets)
+	} else {
+		f.value = &value{}
+	}
+	r.families[name] = f
+	return f
+}
+
+// Writes every instrument in the Prometheus text format, ordered by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mtx.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mtx.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		if f.help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+		if f.kind != histogramKind {
+			fmt.Fprintf(bw, "%s%s %s\n", f.name, braced(f.labels), formatFloat(f.value.load()))
+			continue
+		}
+		counts, sum, count := f.histo.snapshot()
+		var cumulative uint64
+		for i, bound := range f.histo.bounds {
+			cumulative += counts[i]
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, braced(joinLabels(f.labels, `le="`+formatFloat(bound)+`"`)), cumulative)
+		}
+		fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, braced(joinLabels(f.labels, `le="+Inf"`)), count)
+		fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, braced(f.labels), formatFloat(sum))
+		fmt.Fprintf(bw, "%s_count%s %d\n", f.name, braced(f.labels), count)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Serves WriteTo, e.g. on /metrics.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+type value struct {
+	bits atomic.Uint64
+}
+
+func (v *value) Add(delta float64) {
+	for {
+		old := v.bits.Load()
+		if v.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (v *value) Set(val float64) { v.bits.Store(math.Float64bits(val)) }
+func (v *value) load() float64   { return math.Float64frombits(v.bits.Load()) }
+
+type histogram struct {
+	mtx    sync.Mutex
+	bounds []float64
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) Observe(val float64) {
+	i := sort.SearchFloat64s(h.bounds, val)
+	h.mtx.Lock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += val
+	h.count++
+	h.mtx.Unlock()
+}
+
+func (h *histogram) snapshot() ([]uint64, float64, uint64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return append([]uint64(nil), h.counts...), h.sum, h.count
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + `="` + escapeLabel(labels[k]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "," + b
+}
+
+func braced(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
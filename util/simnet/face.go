@@ -0,0 +1,132 @@
+package simnet
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+)
+
+var (
+	ErrCallbacksNotSet = errors.New("face callbacks are not set")
+	ErrFaceRunning     = errors.New("face is already running")
+	ErrFaceNotRunning  = errors.New("face is not running")
+)
+
+// Face connects an engine to the Network. Received packets are handed to the
+// engine one at a time on the Face's own routine.
+type Face struct {
+	net     *Network
+	routes  []enc.Name // guarded by the Network
+	group   int        // guarded by the Network
+	in      chan enc.Buffer
+	done    chan struct{}
+	mtx     sync.Mutex
+	running atomic.Bool
+	onPkt   func(enc.ParseReader) error
+	onError func(error) error
+}
+
+func (f *Face) IsRunning() bool { return f.running.Load() }
+
+func (f *Face) IsLocal() bool { return true }
+
+func (f *Face) SetCallback(onPkt func(enc.ParseReader) error, onError func(error) error) {
+	f.onPkt, f.onError = onPkt, onError
+}
+
+func (f *Face) Open() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.onPkt == nil || f.onError == nil {
+		return ErrCallbacksNotSet
+	}
+	if f.running.Load() {
+		return ErrFaceRunning
+	}
+	f.done = make(chan struct{})
+	f.running.Store(true)
+	go f.run(f.done)
+	return nil
+}
+
+// Routes of the Face are withdrawn.
+func (f *Face) Close() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if !f.running.Load() {
+		return ErrFaceNotRunning
+	}
+	f.running.Store(false)
+	close(f.done)
+	f.net.mtx.Lock()
+	f.routes = nil
+	f.net.mtx.Unlock()
+	return nil
+}
+
+func (f *Face) Send(pkt enc.Wire) error {
+	if !f.running.Load() {
+		return ErrFaceNotRunning
+	}
+	f.net.receive(f, pkt.Join())
+	return nil
+}
+
+func (f *Face) run(done chan struct{}) {
+	for {
+		select {
+		case buf := <-f.in:
+			f.onPkt(enc.NewBufferReader(buf))
+		case <-done:
+			return
+		}
+	}
+}
+
+// Packets beyond the Face's queue are lost, as on a congested link.
+func (f *Face) deliver(buf enc.Buffer, delay time.Duration) {
+	enqueue := func() {
+		if !f.running.Load() {
+			return
+		}
+		select {
+		case f.in <- buf:
+		default:
+		}
+	}
+	if delay <= 0 {
+		enqueue()
+		return
+	}
+	time.AfterFunc(delay, enqueue)
+}
+
+func (f *Face) routed(name enc.Name) bool {
+	for _, route := range f.routes {
+		if route.IsPrefix(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Face) addRoute(prefix enc.Name) {
+	for _, route := range f.routes {
+		if route.Equal(prefix) {
+			return
+		}
+	}
+	f.routes = append(f.routes, prefix)
+}
+
+func (f *Face) removeRoute(prefix enc.Name) {
+	for i, route := range f.routes {
+		if route.Equal(prefix) {
+			f.routes = append(f.routes[:i], f.routes[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Package simnet is an in-memory forwarder for running many engines in one process.
+// Every Face is connected to the same forwarder, which answers the NFD rib commands
+// used by RegisterRoute, multicasts Interests to every Face with a matching route, and
+// returns Data along the pending Interests. Loss, delay, and partitions apply to every
+// packet between Faces. Routes change as soon as a command arrives, its reply is delayed.
+package simnet
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	enc "github.com/zjkmxy/go-ndn/pkg/encoding"
+	eng "github.com/zjkmxy/go-ndn/pkg/engine/basic"
+	ndn "github.com/zjkmxy/go-ndn/pkg/ndn"
+	mgmt "github.com/zjkmxy/go-ndn/pkg/ndn/mgmt_2022"
+	spec "github.com/zjkmxy/go-ndn/pkg/ndn/spec_2022"
+	sec "github.com/zjkmxy/go-ndn/pkg/security"
+	utl "github.com/zjkmxy/go-ndn/pkg/utils"
+)
+
+type pitEntry struct {
+	name        enc.Name
+	canBePrefix bool
+	face        *Face
+	expiry      time.Time
+}
+
+type Network struct {
+	mtx    sync.Mutex
+	faces  []*Face
+	pit    []*pitEntry
+	rng    *rand.Rand
+	loss   float64
+	delay  time.Duration
+	jitter time.Duration
+	nack   bool
+	signer ndn.Signer
+	ribCmd enc.Name
+}
+
+// The seed makes loss and jitter repeatable.
+func NewNetwork(seed int64) *Network {
+	ribCmd, _ := enc.NameFromStr("/localhost/nfd/rib")
+	return &Network{
+		rng:    rand.New(rand.NewSource(seed)),
+		signer: sec.NewSha256Signer(),
+		ribCmd: ribCmd,
+	}
+}
+
+// Each packet between Faces is lost with the given probability.
+func (n *Network) SetLoss(rate float64) {
+	n.mtx.Lock()
+	n.loss = rate
+	n.mtx.Unlock()
+}
+
+// Each packet between Faces arrives after delay plus up to jitter, so packets may be reordered.
+func (n *Network) SetDelay(delay time.Duration, jitter time.Duration) {
+	n.mtx.Lock()
+	n.delay, n.jitter = delay, jitter
+	n.mtx.Unlock()
+}
+
+// Interests that no other Face has a route for are answered with a NoRoute Nack instead of
+// being dropped, as a forwarder without a matching FIB entry would.
+func (n *Network) SetNackNoRoute(nack bool) {
+	n.mtx.Lock()
+	n.nack = nack
+	n.mtx.Unlock()
+}
+
+// Faces only reach Faces of the same group. Faces not given stay in a group of their own.
+func (n *Network) Partition(groups ...[]*Face) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	for _, f := range n.faces {
+		f.group = 0
+	}
+	for i, group := range groups {
+		for _, f := range group {
+			f.group = i + 1
+		}
+	}
+}
+
+func (n *Network) Heal() {
+	n.Partition()
+}
+
+func (n *Network) NewFace() *Face {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	f := &Face{
+		net: n,
+		in:  make(chan enc.Buffer, 1024),
+	}
+	n.faces = append(n.faces, f)
+	return f
+}
+
+// Returns a started engine on the Face. Command Interests and Data are signed with digests.
+func NewEngine(face *Face) (*eng.Engine, error) {
+	timer := eng.NewTimer()
+	passAll := func(enc.Name, enc.Wire, ndn.Signature) bool { return true }
+	app := eng.NewEngine(face, timer, sec.NewSha256IntSigner(timer), passAll)
+	return app, app.Start()
+}
+
+func (n *Network) receive(from *Face, buf enc.Buffer) {
+	pkt, _, err := spec.ReadPacket(enc.NewBufferReader(buf))
+	if err != nil {
+		return
+	}
+	if pkt.LpPacket != nil {
+		if pkt.LpPacket.Nack != nil {
+			return
+		}
+		buf = pkt.LpPacket.Fragment.Join()
+		pkt, _, err = spec.ReadPacket(enc.NewBufferReader(buf))
+		if err != nil {
+			return
+		}
+	}
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	switch {
+	case pkt.Interest != nil && n.ribCmd.IsPrefix(pkt.Interest.NameV):
+		n.command(from, pkt.Interest.NameV)
+	case pkt.Interest != nil:
+		n.onInterest(from, pkt.Interest, buf)
+	case pkt.Data != nil:
+		n.onData(from, pkt.Data.NameV, buf)
+	}
+}
+
+func (n *Network) onInterest(from *Face, interest *spec.Interest, buf enc.Buffer) {
+	lifetime := eng.DefaultInterestLife
+	if interest.InterestLifetimeV != nil {
+		lifetime = *interest.InterestLifetimeV
+	}
+	now := time.Now()
+	n.prune(now)
+	n.pit = append(n.pit, &pitEntry{
+		name:        interest.NameV,
+		canBePrefix: interest.CanBePrefixV,
+		face:        from,
+		expiry:      now.Add(lifetime),
+	})
+	routed := false
+	for _, f := range n.faces {
+		if f != from && f.routed(interest.NameV) {
+			routed = true
+			n.transmit(from, f, buf)
+		}
+	}
+	if !routed && n.nack {
+		n.nackNoRoute(from, buf)
+	}
+}
+
+func (n *Network) nackNoRoute(to *Face, interest enc.Buffer) {
+	pkt := &spec.Packet{
+		LpPacket: &spec.LpPacket{
+			Nack:     &spec.NetworkNack{Reason: spec.NackReasonNoRoute},
+			Fragment: enc.Wire{interest},
+		},
+	}
+	encoder := spec.PacketEncoder{}
+	encoder.Init(pkt)
+	wire := encoder.Encode(pkt)
+	if wire != nil {
+		to.deliver(wire.Join(), n.delay)
+	}
+}
+
+func (n *Network) onData(from *Face, name enc.Name, buf enc.Buffer) {
+	now := time.Now()
+	sent := make(map[*Face]bool)
+	kept := n.pit[:0]
+	for _, e := range n.pit {
+		if !e.expiry.After(now) {
+			continue
+		}
+		if !e.name.Equal(name) && !(e.canBePrefix && e.name.IsPrefix(name)) {
+			kept = append(kept, e)
+			continue
+		}
+		if !sent[e.face] {
+			sent[e.face] = true
+			n.transmit(from, e.face, buf)
+		}
+	}
+	n.pit = kept
+}
+
+func (n *Network) prune(now time.Time) {
+	kept := n.pit[:0]
+	for _, e := range n.pit {
+		if e.expiry.After(now) {
+			kept = append(kept, e)
+		}
+	}
+	n.pit = kept
+}
+
+func (n *Network) transmit(from *Face, to *Face, buf enc.Buffer) {
+	if from.group != to.group {
+		return
+	}
+	if n.loss > 0 && n.rng.Float64() < n.loss {
+		return
+	}
+	delay := n.delay
+	if n.jitter > 0 {
+		delay += time.Duration(n.rng.Int63n(int64(n.jitter)))
+	}
+	to.deliver(buf, delay)
+}
+
+// Handles rib register and unregister, the only commands engines send.
+func (n *Network) command(from *Face, name enc.Name) {
+	if len(name) < 5 {
+		return
+	}
+	params, err := mgmt.ParseControlParameters(enc.NewBufferReader(name[4].Val), true)
+	if err != nil || params.Val == nil {
+		return
+	}
+	switch string(name[3].Val) {
+	case "register":
+		from.addRoute(params.Val.Name)
+	case "unregister":
+		from.removeRoute(params.Val.Name)
+	default:
+		return
+	}
+	resp := &mgmt.ControlResponse{
+		Val: &mgmt.ControlResponseVal{StatusCode: 200, StatusText: "OK", Params: params.Val},
+	}
+	cfg := &ndn.DataConfig{ContentType: utl.IdPtr(ndn.ContentTypeBlob)}
+	wire, _, err := spec.Spec{}.MakeData(name, cfg, resp.Encode(), n.signer)
+	if err == nil {
+		from.deliver(wire.Join(), n.delay)
+	}
+}